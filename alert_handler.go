@@ -0,0 +1,422 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultAlertQueueSize      = 64
+	defaultAlertDigestInterval = time.Minute
+	defaultAlertTimeout        = 10 * time.Second
+	defaultAlertDigestMax      = 50
+	maxAlertValueLen           = 300
+)
+
+// AlertFormat selects the markup flavor of the webhook payload.
+type AlertFormat int
+
+const (
+	AlertSlack AlertFormat = iota
+	AlertMattermost
+)
+
+type AlertOptions struct {
+	// WebhookURL is the incoming webhook endpoint, required.
+	WebhookURL string
+	// Level is the minimum level that is posted, if not set, Error is used.
+	Level  slog.Leveler
+	Format AlertFormat
+
+	Username  string
+	Channel   string
+	IconEmoji string
+
+	// Keys limits the posted attributes to the listed group-qualified keys, all attributes are posted if empty.
+	Keys      []string
+	AddSource bool
+
+	// Cooldown suppresses alerts with the same fingerprint for the given duration.
+	Cooldown time.Duration
+	// Fingerprint groups similar records, by default level, message and source are used.
+	Fingerprint func(r slog.Record) string
+
+	// Digest batches alerts into one message per DigestInterval (one minute by default).
+	Digest         bool
+	DigestInterval time.Duration
+	// DigestMax caps the alerts listed in one digest (50 by default), the rest are only counted
+	// so the payload stays within webhook limits.
+	DigestMax int
+
+	Client *http.Client
+	// OnError is called when a payload could not be delivered or was dropped.
+	OnError func(error)
+}
+
+// AlertHandler posts records to a Slack or Mattermost compatible webhook.
+type AlertHandler struct {
+	core   *alertCore
+	prefix []string
+	fields []alertField
+}
+
+type alertField struct {
+	key   string
+	value string
+}
+
+type alertEntry struct {
+	level      slog.Level
+	time       time.Time
+	message    string
+	source     string
+	fields     []alertField
+	suppressed int
+}
+
+type alertCore struct {
+	opts AlertOptions
+	keys map[string]bool
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+	skipped  map[string]int
+	digest   []alertEntry
+	// omitted counts the alerts of the pending digest that exceeded DigestMax.
+	omitted int
+	closed  bool
+
+	queue chan []byte
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+var ErrAlertQueueFull = errors.New("alert queue is full")
+
+func NewAlertHandler(opts *AlertOptions) *AlertHandler {
+	if opts == nil {
+		opts = &AlertOptions{}
+	}
+
+	c := &alertCore{
+		opts:     *opts,
+		lastSent: make(map[string]time.Time),
+		skipped:  make(map[string]int),
+		queue:    make(chan []byte, defaultAlertQueueSize),
+		done:     make(chan struct{}),
+	}
+
+	if c.opts.Level == nil {
+		c.opts.Level = LevelError
+	}
+	if c.opts.Client == nil {
+		c.opts.Client = &http.Client{Timeout: defaultAlertTimeout}
+	}
+	if c.opts.Fingerprint == nil {
+		c.opts.Fingerprint = defaultAlertFingerprint
+	}
+	if c.opts.Digest && c.opts.DigestInterval <= 0 {
+		c.opts.DigestInterval = defaultAlertDigestInterval
+	}
+	if c.opts.DigestMax <= 0 {
+		c.opts.DigestMax = defaultAlertDigestMax
+	}
+	if len(c.opts.Keys) > 0 {
+		c.keys = make(map[string]bool, len(c.opts.Keys))
+		for _, k := range c.opts.Keys {
+			c.keys[k] = true
+		}
+	}
+
+	c.wg.Add(1)
+	go c.run()
+
+	return &AlertHandler{core: c}
+}
+
+/*--------------------------------slog methods-----------------------------------------------*/
+
+func (h *AlertHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.core.opts.Level.Level()
+}
+
+func (h *AlertHandler) Handle(_ context.Context, r slog.Record) error {
+	e := alertEntry{
+		level:   r.Level,
+		time:    r.Time,
+		message: r.Message,
+		fields:  append([]alertField(nil), h.fields...),
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		e.fields = h.core.appendFields(e.fields, h.prefix, a)
+		return true
+	})
+
+	if h.core.opts.AddSource && r.PC != 0 {
+		fs := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := fs.Next()
+		e.source = fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+
+	return h.core.add(h.core.opts.Fingerprint(r), e)
+}
+
+func (h *AlertHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.fields = append([]alertField(nil), h.fields...)
+	for _, a := range attrs {
+		h2.fields = h.core.appendFields(h2.fields, h.prefix, a)
+	}
+	return &h2
+}
+
+func (h *AlertHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = append(h.prefix[:len(h.prefix):len(h.prefix)], name)
+	return &h2
+}
+
+// Close flushes a pending digest and waits until queued payloads are delivered.
+func (h *AlertHandler) Close() error {
+	c := h.core
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	close(c.done)
+	c.wg.Wait()
+	return nil
+}
+
+/*--------------------------------CORE-------------------------------------------------------*/
+
+func (c *alertCore) appendFields(fields []alertField, prefix []string, a slog.Attr) []alertField {
+	flattenAttr(prefix, a, func(key string, v slog.Value) {
+		if c.keys != nil && !c.keys[key] {
+			return
+		}
+		fields = append(fields, alertField{key: key, value: truncateAlertValue(v.String())})
+	})
+	return fields
+}
+
+func (c *alertCore) add(fingerprint string, e alertEntry) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+
+	if c.opts.Cooldown > 0 {
+		now := time.Now()
+		if last, ok := c.lastSent[fingerprint]; ok && now.Sub(last) < c.opts.Cooldown {
+			c.skipped[fingerprint]++
+			c.mu.Unlock()
+			return nil
+		}
+		c.lastSent[fingerprint] = now
+		e.suppressed = c.skipped[fingerprint]
+		delete(c.skipped, fingerprint)
+		c.gcCooldown(now)
+	}
+
+	if c.opts.Digest {
+		if len(c.digest) < c.opts.DigestMax {
+			c.digest = append(c.digest, e)
+		} else {
+			c.omitted++
+		}
+		c.mu.Unlock()
+		return nil
+	}
+
+	// enqueue under the lock, so Close cannot stop the worker between the closed check and the send
+	defer c.mu.Unlock()
+	return c.enqueue(c.payload(c.formatEntry(e)))
+}
+
+// gcCooldown forgets fingerprints whose cooldown has expired, so the maps do not grow forever.
+func (c *alertCore) gcCooldown(now time.Time) {
+	if len(c.lastSent) < 1024 {
+		return
+	}
+	for fp, last := range c.lastSent {
+		if now.Sub(last) >= c.opts.Cooldown && c.skipped[fp] == 0 {
+			delete(c.lastSent, fp)
+		}
+	}
+}
+
+func (c *alertCore) enqueue(p []byte) error {
+	select {
+	case c.queue <- p:
+		return nil
+	default:
+		c.reportError(ErrAlertQueueFull)
+		return ErrAlertQueueFull
+	}
+}
+
+func (c *alertCore) run() {
+	defer c.wg.Done()
+
+	var tick <-chan time.Time
+	if c.opts.Digest {
+		t := time.NewTicker(c.opts.DigestInterval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		select {
+		case p := <-c.queue:
+			c.post(p)
+		case <-tick:
+			c.flushDigest()
+		case <-c.done:
+			c.flushDigest()
+			for {
+				select {
+				case p := <-c.queue:
+					c.post(p)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *alertCore) flushDigest() {
+	c.mu.Lock()
+	entries, omitted := c.digest, c.omitted
+	c.digest, c.omitted = nil, 0
+	c.mu.Unlock()
+
+	if len(entries) == 0 {
+		return
+	}
+	if len(entries) == 1 && omitted == 0 {
+		c.post(c.payload(c.formatEntry(entries[0])))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(c.bold(fmt.Sprintf("%d alerts in the last %s", len(entries)+omitted, c.opts.DigestInterval)))
+	for _, e := range entries {
+		sb.WriteString("\n\n")
+		sb.WriteString(c.formatEntry(e))
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&sb, "\n\n_…and %d more_", omitted)
+	}
+	c.post(c.payload(sb.String()))
+}
+
+func (c *alertCore) post(p []byte) {
+	resp, err := c.opts.Client.Post(c.opts.WebhookURL, "application/json", bytes.NewReader(p))
+	if err != nil {
+		c.reportError(err)
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		c.reportError(fmt.Errorf("alert webhook responded with %s", resp.Status))
+	}
+}
+
+func (c *alertCore) reportError(err error) {
+	if c.opts.OnError != nil {
+		c.opts.OnError(err)
+	}
+}
+
+/*--------------------------------FORMAT-----------------------------------------------------*/
+
+func (c *alertCore) formatEntry(e alertEntry) string {
+	var sb strings.Builder
+
+	emoji := levelEmoji[e.level]
+	if emoji == "" {
+		emoji = levelEmoji[LevelError]
+	}
+	fmt.Fprintf(&sb, "%s %s %s", strings.TrimSpace(emoji), c.bold(e.level.String()), e.message)
+	if !e.time.IsZero() {
+		fmt.Fprintf(&sb, "  _%s_", e.time.Format(time.RFC3339))
+	}
+
+	for _, f := range e.fields {
+		fmt.Fprintf(&sb, "\n• %s: `%s`", c.bold(f.key), f.value)
+	}
+	if e.source != "" {
+		fmt.Fprintf(&sb, "\n• %s: `%s`", c.bold("source"), e.source)
+	}
+	if e.suppressed > 0 {
+		fmt.Fprintf(&sb, "\n_%d similar alerts suppressed_", e.suppressed)
+	}
+	return sb.String()
+}
+
+func (c *alertCore) bold(s string) string {
+	if c.opts.Format == AlertMattermost {
+		return "**" + s + "**"
+	}
+	return "*" + s + "*"
+}
+
+func (c *alertCore) payload(text string) []byte {
+	p := struct {
+		Text      string `json:"text"`
+		Username  string `json:"username,omitempty"`
+		Channel   string `json:"channel,omitempty"`
+		IconEmoji string `json:"icon_emoji,omitempty"`
+	}{text, c.opts.Username, c.opts.Channel, c.opts.IconEmoji}
+
+	b, _ := json.Marshal(p)
+	return b
+}
+
+func defaultAlertFingerprint(r slog.Record) string {
+	var file string
+	var line int
+	if r.PC != 0 {
+		fs := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := fs.Next()
+		file, line = f.File, f.Line
+	}
+	return fmt.Sprintf("%s|%s|%s:%d", r.Level, r.Message, file, line)
+}
+
+func truncateAlertValue(s string) string {
+	if len(s) <= maxAlertValueLen {
+		return s
+	}
+	cut := maxAlertValueLen
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
@@ -0,0 +1,138 @@
+package logger
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhook collects the texts of the posted payloads.
+type webhook struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func newWebhook(t *testing.T) (*webhook, string) {
+	t.Helper()
+	wh := &webhook{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		wh.mu.Lock()
+		wh.texts = append(wh.texts, p.Text)
+		wh.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return wh, srv.URL
+}
+
+func (wh *webhook) posted() []string {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	return append([]string(nil), wh.texts...)
+}
+
+func TestAlertHandlerPosts(t *testing.T) {
+	wh, url := newWebhook(t)
+	h := NewAlertHandler(&AlertOptions{WebhookURL: url, Format: AlertMattermost, Keys: []string{"req.id", "user"}})
+	l := slog.New(h).With("user", "bob", "service", "api")
+
+	l.Info("ignored")
+	l.WithGroup("req").Error("payment failed", "id", 7, "card", "4111")
+	h.Close()
+
+	texts := wh.posted()
+	if len(texts) != 1 {
+		t.Fatalf("posted %q", texts)
+	}
+	text := texts[0]
+	for _, want := range []string{"**ERROR** payment failed", "• **user**: `bob`", "• **req.id**: `7`"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("payload %q does not contain %q", text, want)
+		}
+	}
+	if strings.Contains(text, "service") || strings.Contains(text, "4111") {
+		t.Fatalf("payload %q has attributes outside Keys", text)
+	}
+}
+
+func TestAlertHandlerCooldown(t *testing.T) {
+	wh, url := newWebhook(t)
+	h := NewAlertHandler(&AlertOptions{WebhookURL: url, Cooldown: 50 * time.Millisecond})
+	l := slog.New(h)
+
+	// one call site, so the default fingerprint only differs by message
+	alert := func(msg string) { l.Error(msg) }
+	for range 3 {
+		alert("db down")
+	}
+	alert("disk full")
+	time.Sleep(60 * time.Millisecond)
+	alert("db down")
+	h.Close()
+
+	texts := wh.posted()
+	if len(texts) != 3 {
+		t.Fatalf("posted %q, want the first of each fingerprint and one after the cooldown", texts)
+	}
+	if strings.Contains(texts[0], "suppressed") || !strings.Contains(texts[2], "db down") || !strings.Contains(texts[2], "_2 similar alerts suppressed_") {
+		t.Fatalf("posted %q", texts)
+	}
+}
+
+func TestAlertHandlerDigest(t *testing.T) {
+	wh, url := newWebhook(t)
+	h := NewAlertHandler(&AlertOptions{WebhookURL: url, Digest: true, DigestInterval: time.Hour, DigestMax: 3})
+	l := slog.New(h)
+
+	for i := range 5 {
+		l.Error("job failed", "job", i)
+	}
+	if texts := wh.posted(); len(texts) != 0 {
+		t.Fatalf("posted before the digest interval: %q", texts)
+	}
+	h.Close()
+
+	texts := wh.posted()
+	if len(texts) != 1 {
+		t.Fatalf("posted %q, want one digest", texts)
+	}
+	text := texts[0]
+	if !strings.HasPrefix(text, "*5 alerts in the last 1h0m0s*") || !strings.HasSuffix(text, "_…and 2 more_") {
+		t.Fatalf("digest = %q", text)
+	}
+	if n := strings.Count(text, "job failed"); n != 3 {
+		t.Fatalf("digest lists %d alerts, want DigestMax 3:\n%s", n, text)
+	}
+	if strings.Contains(text, "`3`") {
+		t.Fatalf("digest lists an alert past DigestMax:\n%s", text)
+	}
+
+	// a single alert is posted as is
+	wh, url = newWebhook(t)
+	h = NewAlertHandler(&AlertOptions{WebhookURL: url, Digest: true, DigestInterval: time.Hour})
+	slog.New(h).Error("once")
+	h.Close()
+	if texts := wh.posted(); len(texts) != 1 || strings.Contains(texts[0], "alerts in the last") {
+		t.Fatalf("posted %q", texts)
+	}
+}
+
+func TestTruncateAlertValue(t *testing.T) {
+	long := strings.Repeat("a", maxAlertValueLen-1) + "éé"
+	if got, want := truncateAlertValue(long), strings.Repeat("a", maxAlertValueLen-1)+"…"; got != want {
+		t.Fatalf("truncateAlertValue = %q, want %q", got, want)
+	}
+	if got := truncateAlertValue("short"); got != "short" {
+		t.Fatalf("truncateAlertValue = %q", got)
+	}
+}
@@ -0,0 +1,426 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAuditCheckpointEvery = 1000
+
+	auditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+// AuditState is the position of a hash chain, it is used to continue an existing audit log.
+type AuditState struct {
+	Seq  uint64
+	Hash string
+}
+
+type AuditOptions struct {
+	Level     slog.Leveler
+	AddSource bool
+
+	// CheckpointKey signs periodic checkpoints with HMAC-SHA256, checkpoints are not written if it is empty.
+	// Checkpoints are part of the hash chain, one is written every CheckpointEvery records (1000 by default)
+	// and on Close, so VerifyAudit detects a truncated tail.
+	CheckpointKey   []byte
+	CheckpointEvery int
+
+	// State continues the chain of an existing log, as returned by VerifyAudit.
+	State AuditState
+}
+
+// AuditHandler writes tamper-evident JSON lines. Every record carries a sequence number,
+// the hash of the previous record and its own SHA-256 hash computed over the line without the hash field:
+//
+//	{"seq":1,"prev_hash":"000…","time":"…","level":"INFO","msg":"login","user":"bob","hash":"9f2…"}
+//
+// Deleting, reordering or editing a line breaks the chain, which VerifyAudit and cmd/logaudit detect.
+// With a CheckpointKey, Close must be called so the log ends with a checkpoint.
+type AuditHandler struct {
+	core *auditCore
+	enc  slog.Handler
+}
+
+type auditCore struct {
+	w    io.Writer
+	opts AuditOptions
+
+	mu              sync.Mutex
+	encBuf          bytes.Buffer
+	seq             uint64
+	hash            string
+	sinceCheckpoint int
+	closed          bool
+}
+
+var ErrAuditClosed = errors.New("audit log is closed")
+
+// AuditError tells where an audit chain breaks.
+type AuditError struct {
+	Line   int
+	Seq    uint64
+	Reason string
+}
+
+func (e *AuditError) Error() string {
+	return fmt.Sprintf("audit chain broken at line %d (seq %d): %s", e.Line, e.Seq, e.Reason)
+}
+
+func NewAuditHandler(w io.Writer, opts *AuditOptions) *AuditHandler {
+	if opts == nil {
+		opts = &AuditOptions{}
+	}
+
+	c := &auditCore{
+		w:    w,
+		opts: *opts,
+		seq:  opts.State.Seq,
+		hash: opts.State.Hash,
+	}
+	if c.hash == "" {
+		c.hash = auditGenesisHash
+	}
+	if c.opts.Level == nil {
+		c.opts.Level = LevelInfo
+	}
+	if c.opts.CheckpointEvery <= 0 {
+		c.opts.CheckpointEvery = defaultAuditCheckpointEvery
+	}
+
+	h := &AuditHandler{core: c}
+	h.enc = slog.NewJSONHandler(&c.encBuf, &slog.HandlerOptions{
+		Level:     c.opts.Level,
+		AddSource: c.opts.AddSource,
+	})
+	return h
+}
+
+/*--------------------------------slog methods-----------------------------------------------*/
+
+func (h *AuditHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.core.opts.Level.Level()
+}
+
+func (h *AuditHandler) Handle(ctx context.Context, r slog.Record) error {
+	c := h.core
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrAuditClosed
+	}
+
+	c.encBuf.Reset()
+	if err := h.enc.Handle(ctx, r); err != nil {
+		return err
+	}
+	body := bytes.TrimSpace(c.encBuf.Bytes())
+	if len(body) < 2 || body[0] != '{' {
+		return fmt.Errorf("audit: unexpected record encoding")
+	}
+
+	seq := c.seq + 1
+	line := make([]byte, 0, len(body)+160)
+	line = append(line, `{"seq":`...)
+	line = strconv.AppendUint(line, seq, 10)
+	line = append(line, `,"prev_hash":"`...)
+	line = append(line, c.hash...)
+	line = append(line, '"')
+	if rest := body[1:]; len(rest) > 1 {
+		line = append(line, ',')
+		line = append(line, rest...)
+	} else {
+		line = append(line, '}')
+	}
+
+	hash := auditHash(line)
+	line = appendAuditHash(line, hash)
+
+	if _, err := c.w.Write(line); err != nil {
+		return err
+	}
+	c.seq, c.hash = seq, hash
+
+	c.sinceCheckpoint++
+	if len(c.opts.CheckpointKey) > 0 && c.sinceCheckpoint >= c.opts.CheckpointEvery {
+		return c.writeCheckpoint(false)
+	}
+	return nil
+}
+
+func (h *AuditHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &AuditHandler{core: h.core, enc: h.enc.WithAttrs(attrs)}
+}
+
+func (h *AuditHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &AuditHandler{core: h.core, enc: h.enc.WithGroup(name)}
+}
+
+// State returns the current chain position.
+func (h *AuditHandler) State() AuditState {
+	h.core.mu.Lock()
+	defer h.core.mu.Unlock()
+	return AuditState{Seq: h.core.seq, Hash: h.core.hash}
+}
+
+// Checkpoint writes a signed checkpoint now, for example before shutdown.
+func (h *AuditHandler) Checkpoint() error {
+	h.core.mu.Lock()
+	defer h.core.mu.Unlock()
+	if h.core.closed {
+		return ErrAuditClosed
+	}
+	if len(h.core.opts.CheckpointKey) == 0 {
+		return nil
+	}
+	return h.core.writeCheckpoint(false)
+}
+
+// Close writes the final checkpoint, records and checkpoints after Close return ErrAuditClosed.
+// The writer is not closed.
+func (h *AuditHandler) Close() error {
+	c := h.core
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if len(c.opts.CheckpointKey) == 0 {
+		return nil
+	}
+	return c.writeCheckpoint(true)
+}
+
+// writeCheckpoint writes a signed checkpoint of the chain, the checkpoint hash becomes the
+// prev_hash of the next record so a deleted checkpoint breaks the chain. The final checkpoint
+// written by Close marks the end of the log.
+func (c *auditCore) writeCheckpoint(final bool) error {
+	cp := auditCheckpoint{
+		Seq:        c.seq,
+		Checkpoint: c.hash,
+		Time:       time.Now().UTC().Format(time.RFC3339Nano),
+		Final:      final,
+	}
+	cp.Sig = cp.sign(c.opts.CheckpointKey)
+
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	if _, err = c.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	c.hash = auditHash(b)
+	c.sinceCheckpoint = 0
+	return nil
+}
+
+/*--------------------------------CHAIN------------------------------------------------------*/
+
+type auditCheckpoint struct {
+	Seq        uint64 `json:"seq"`
+	Checkpoint string `json:"checkpoint"`
+	Time       string `json:"time"`
+	Final      bool   `json:"final,omitempty"`
+	Sig        string `json:"sig"`
+}
+
+func (cp auditCheckpoint) sign(key []byte) string {
+	m := hmac.New(sha256.New, key)
+	fmt.Fprintf(m, "%d:%s:%s", cp.Seq, cp.Checkpoint, cp.Time)
+	if cp.Final {
+		io.WriteString(m, ":final")
+	}
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+func auditHash(canonical []byte) string {
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// appendAuditHash turns the canonical line {…} into {…,"hash":"…"}\n.
+func appendAuditHash(canonical []byte, hash string) []byte {
+	line := canonical[:len(canonical)-1]
+	line = append(line, `,"hash":"`...)
+	line = append(line, hash...)
+	return append(line, "\"}\n"...)
+}
+
+// splitAuditHash is the inverse of appendAuditHash.
+func splitAuditHash(line []byte) (canonical []byte, hash string, ok bool) {
+	const suffixLen = len(`,"hash":""}`) + 64
+	if len(line) < suffixLen+1 {
+		return nil, "", false
+	}
+	suffix := line[len(line)-suffixLen:]
+	if !bytes.HasPrefix(suffix, []byte(`,"hash":"`)) || !bytes.HasSuffix(suffix, []byte(`"}`)) {
+		return nil, "", false
+	}
+	hash = string(suffix[len(`,"hash":"`) : len(suffix)-2])
+	canonical = append(bytes.Clone(line[:len(line)-suffixLen]), '}')
+	return canonical, hash, true
+}
+
+type AuditVerifyOptions struct {
+	// Key verifies the checkpoint signatures and requires a checkpoint every CheckpointEvery
+	// records and the final checkpoint of Close at the end of the log, without it checkpoints are
+	// only matched against the chain.
+	Key []byte
+	// CheckpointEvery is the AuditOptions.CheckpointEvery the log was written with, 1000 by default.
+	CheckpointEvery int
+	// Start continues the chain of a log that follows another one, such as a rotated file.
+	Start AuditState
+}
+
+// VerifyAudit reads an audit log from its first record and checks the hash chain and,
+// if key is set, the checkpoints. It returns the last valid chain state,
+// and an *AuditError describing the first break.
+func VerifyAudit(r io.Reader, key []byte) (AuditState, error) {
+	return VerifyAuditWith(r, &AuditVerifyOptions{Key: key})
+}
+
+// VerifyAuditFrom is VerifyAudit for a log that continues the chain at start, such as a rotated file.
+func VerifyAuditFrom(r io.Reader, key []byte, start AuditState) (AuditState, error) {
+	return VerifyAuditWith(r, &AuditVerifyOptions{Key: key, Start: start})
+}
+
+// VerifyAuditWith is VerifyAudit with all verification options.
+func VerifyAuditWith(r io.Reader, opts *AuditVerifyOptions) (AuditState, error) {
+	if opts == nil {
+		opts = &AuditVerifyOptions{}
+	}
+	every := opts.CheckpointEvery
+	if every <= 0 {
+		every = defaultAuditCheckpointEvery
+	}
+	key := opts.Key
+
+	state := opts.Start
+	if state.Hash == "" {
+		state.Hash = auditGenesisHash
+	}
+	sinceCheckpoint := 0
+	final := false
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		seq, prevHash, isCheckpoint, ok := parseAuditHead(line)
+		if final {
+			return state, &AuditError{lineNo, seq, "line follows the final checkpoint"}
+		}
+		if !ok {
+			return state, &AuditError{lineNo, state.Seq + 1, "line is not an audit record"}
+		}
+
+		if isCheckpoint {
+			cp, err := verifyAuditCheckpoint(line, state, key)
+			if err != "" {
+				return state, &AuditError{lineNo, seq, err}
+			}
+			final = cp.Final
+			state.Hash = auditHash(line)
+			sinceCheckpoint = 0
+			continue
+		}
+
+		switch expected := state.Seq + 1; {
+		case seq < expected:
+			return state, &AuditError{lineNo, seq, fmt.Sprintf("expected seq %d, line reordered or duplicated", expected)}
+		case seq > expected:
+			return state, &AuditError{lineNo, seq, fmt.Sprintf("expected seq %d, %d records missing", expected, seq-expected)}
+		case prevHash != state.Hash:
+			return state, &AuditError{lineNo, seq, "prev_hash does not match the previous record"}
+		}
+
+		canonical, hash, ok := splitAuditHash(line)
+		if !ok {
+			return state, &AuditError{lineNo, seq, "hash field is missing or not last"}
+		}
+		if auditHash(canonical) != hash {
+			return state, &AuditError{lineNo, seq, "record was modified, hash does not match its content"}
+		}
+
+		state = AuditState{Seq: seq, Hash: hash}
+		sinceCheckpoint++
+		if len(key) > 0 && sinceCheckpoint > every {
+			return state, &AuditError{lineNo, seq, fmt.Sprintf("checkpoint missing, more than %d records since the last one", every)}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return state, err
+	}
+	if len(key) > 0 && !final {
+		return state, &AuditError{lineNo + 1, state.Seq, "log ends without the final checkpoint, it was truncated or not closed"}
+	}
+	return state, nil
+}
+
+// parseAuditHead reads the fixed {"seq":N,"prev_hash":"…" or {"seq":N,"checkpoint":"…" prefix,
+// so attributes that reuse these keys can't shadow them.
+func parseAuditHead(line []byte) (seq uint64, prevHash string, isCheckpoint, ok bool) {
+	rest, found := bytes.CutPrefix(line, []byte(`{"seq":`))
+	if !found {
+		return 0, "", false, false
+	}
+	end := bytes.IndexByte(rest, ',')
+	if end < 0 {
+		return 0, "", false, false
+	}
+	seq, err := strconv.ParseUint(string(rest[:end]), 10, 64)
+	if err != nil {
+		return 0, "", false, false
+	}
+	rest = rest[end:]
+
+	if bytes.HasPrefix(rest, []byte(`,"checkpoint":"`)) {
+		return seq, "", true, true
+	}
+	if rest, found = bytes.CutPrefix(rest, []byte(`,"prev_hash":"`)); !found || len(rest) < 65 || rest[64] != '"' {
+		return 0, "", false, false
+	}
+	return seq, string(rest[:64]), false, true
+}
+
+func verifyAuditCheckpoint(line []byte, state AuditState, key []byte) (auditCheckpoint, string) {
+	var cp auditCheckpoint
+	if err := json.Unmarshal(line, &cp); err != nil {
+		return cp, "malformed checkpoint"
+	}
+	if cp.Seq != state.Seq || cp.Checkpoint != state.Hash {
+		return cp, fmt.Sprintf("checkpoint for seq %d does not match the chain at seq %d", cp.Seq, state.Seq)
+	}
+	if len(key) > 0 && !hmac.Equal([]byte(cp.sign(key)), []byte(cp.Sig)) {
+		return cp, "checkpoint signature is invalid"
+	}
+	return cp, ""
+}
@@ -0,0 +1,159 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testAuditKey = []byte("audit-test-key")
+
+// writeAudit writes n records with a checkpoint every 3 of them and closes the handler.
+func writeAudit(t *testing.T, n int) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	h := NewAuditHandler(&buf, &AuditOptions{CheckpointKey: testAuditKey, CheckpointEvery: 3})
+	l := slog.New(h).With("service", "billing")
+	for i := range n {
+		l.Info("charge", "i", i)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")
+	return lines[:len(lines)-1]
+}
+
+func verifyAuditLines(lines []string, key []byte) (AuditState, error) {
+	return VerifyAuditWith(strings.NewReader(strings.Join(lines, "")), &AuditVerifyOptions{Key: key, CheckpointEvery: 3})
+}
+
+func TestAuditVerify(t *testing.T) {
+	lines := writeAudit(t, 7)
+	// 7 records, checkpoints after 3 and 6 and the final one
+	if len(lines) != 10 {
+		t.Fatalf("%d lines:\n%s", len(lines), strings.Join(lines, ""))
+	}
+
+	state, err := verifyAuditLines(lines, testAuditKey)
+	if err != nil || state.Seq != 7 {
+		t.Fatalf("state = %+v, err = %v", state, err)
+	}
+	if _, err := verifyAuditLines(lines, nil); err != nil {
+		t.Fatalf("chain without key: %v", err)
+	}
+}
+
+func TestAuditVerifyDetectsBreaks(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func([]string) []string
+		key    []byte
+		reason string
+	}{
+		{"modified record", func(l []string) []string {
+			l[1] = strings.Replace(l[1], `"i":1`, `"i":9`, 1)
+			return l
+		}, testAuditKey, "record was modified"},
+		{"deleted record", func(l []string) []string {
+			return append(l[:1:1], l[2:]...)
+		}, testAuditKey, "records missing"},
+		{"duplicated record", func(l []string) []string {
+			return append(l[:2:2], l[1:]...)
+		}, testAuditKey, "reordered or duplicated"},
+		{"deleted checkpoint", func(l []string) []string {
+			return append(l[:3:3], l[4:]...)
+		}, testAuditKey, "prev_hash does not match"},
+		{"truncated at a checkpoint", func(l []string) []string {
+			return l[:4]
+		}, testAuditKey, "without the final checkpoint"},
+		{"final checkpoint removed", func(l []string) []string {
+			return l[:len(l)-1]
+		}, testAuditKey, "without the final checkpoint"},
+		{"line after the final checkpoint", func(l []string) []string {
+			return append(l, l[0])
+		}, testAuditKey, "follows the final checkpoint"},
+		{"wrong key", func(l []string) []string {
+			return l
+		}, []byte("other-key"), "signature is invalid"},
+		{"not a record", func(l []string) []string {
+			l[2] = `{"msg":"injected"}` + "\n"
+			return l
+		}, testAuditKey, "not an audit record"},
+	}
+	for _, tt := range tests {
+		_, err := verifyAuditLines(tt.edit(writeAudit(t, 7)), tt.key)
+		var ae *AuditError
+		if !errors.As(err, &ae) || !strings.Contains(ae.Reason, tt.reason) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.reason)
+		}
+	}
+}
+
+func TestAuditVerifyMissingCheckpoint(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewAuditHandler(&buf, &AuditOptions{CheckpointKey: testAuditKey, CheckpointEvery: 10})
+	for range 5 {
+		slog.New(h).Info("charge")
+	}
+	h.Close()
+
+	_, err := VerifyAuditWith(bytes.NewReader(buf.Bytes()), &AuditVerifyOptions{Key: testAuditKey, CheckpointEvery: 3})
+	var ae *AuditError
+	if !errors.As(err, &ae) || ae.Seq != 4 || !strings.Contains(ae.Reason, "checkpoint missing") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestAuditContinuesChain(t *testing.T) {
+	var first, second bytes.Buffer
+	h := NewAuditHandler(&first, nil)
+	slog.New(h).Info("a")
+	slog.New(h).Info("b")
+
+	h2 := NewAuditHandler(&second, &AuditOptions{State: h.State()})
+	slog.New(h2).Info("c")
+
+	state, err := VerifyAudit(&first, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyAudit(bytes.NewReader(second.Bytes()), nil); err == nil {
+		t.Fatal("continued log verified from the genesis hash")
+	}
+	if end, err := VerifyAuditFrom(&second, nil, state); err != nil || end.Seq != 3 {
+		t.Fatalf("state = %+v, err = %v", end, err)
+	}
+}
+
+func TestAuditHandlerClosed(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewAuditHandler(&buf, &AuditOptions{CheckpointKey: testAuditKey})
+	l := slog.New(h.WithGroup("req"))
+	l.Info("before")
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	n := buf.Len()
+
+	r := slog.NewRecord(time.Now(), LevelInfo, "after", 0)
+	if err := h.WithAttrs([]slog.Attr{slog.Int("id", 1)}).Handle(context.Background(), r); !errors.Is(err, ErrAuditClosed) {
+		t.Fatalf("Handle after Close = %v", err)
+	}
+	if err := h.Checkpoint(); !errors.Is(err, ErrAuditClosed) {
+		t.Fatalf("Checkpoint after Close = %v", err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("second Close = %v", err)
+	}
+	if buf.Len() != n {
+		t.Fatalf("written after Close: %q", buf.String()[n:])
+	}
+	if _, err := VerifyAudit(&buf, testAuditKey); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,74 @@
+// Command logaudit checks audit logs written by logger.NewAuditHandler.
+//
+//	logaudit verify [-key BASE64 [-every N]] [-from-seq N -from-hash HEX] audit.log
+//
+// verify exits with status 1 and prints the line where the hash chain breaks
+// if a record or checkpoint was deleted, reordered or modified, or the log was truncated.
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/FurmanovVitaliy/logger"
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "verify" {
+		fmt.Fprintln(os.Stderr, "usage: logaudit verify [-key BASE64 [-every N]] [-from-seq N -from-hash HEX] [file]")
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	keyB64 := fs.String("key", "", "base64 HMAC key used to sign checkpoints")
+	every := fs.Int("every", 0, "records between checkpoints the log was written with (default 1000)")
+	fromSeq := fs.Uint64("from-seq", 0, "sequence number the file continues from (rotated logs)")
+	fromHash := fs.String("from-hash", "", "hash of the record the file continues from (rotated logs)")
+	fs.Parse(os.Args[2:])
+
+	var key []byte
+	if *keyB64 != "" {
+		var err error
+		if key, err = base64.StdEncoding.DecodeString(*keyB64); err != nil {
+			fatal(fmt.Errorf("key: %w", err))
+		}
+	}
+
+	var in io.Reader = os.Stdin
+	name := "stdin"
+	if fs.NArg() > 0 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		in, name = f, fs.Arg(0)
+	}
+
+	state, err := logger.VerifyAuditWith(in, &logger.AuditVerifyOptions{
+		Key:             key,
+		CheckpointEvery: *every,
+		Start:           logger.AuditState{Seq: *fromSeq, Hash: *fromHash},
+	})
+
+	var ae *logger.AuditError
+	if errors.As(err, &ae) {
+		fmt.Printf("%s: BROKEN at line %d (seq %d): %s\n", name, ae.Line, ae.Seq, ae.Reason)
+		fmt.Printf("last valid record: seq %d hash %s\n", state.Seq, state.Hash)
+		os.Exit(1)
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Printf("%s: OK, %d records, last hash %s\n", name, state.Seq-*fromSeq, state.Hash)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "logaudit:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,229 @@
+// Command logdecrypt decrypts fields written by logger.NewEncryptHandler in JSON log files.
+//
+//	logdecrypt -key k1=BASE64KEY -key k0=BASE64KEY app.log > app.plain.log
+//	cat app.log | logdecrypt -keys keys.txt
+//
+// A keys file holds one kid=base64 pair per line. Lines that are not JSON are copied as is,
+// fields that can't be decrypted are left encrypted and reported on stderr.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/FurmanovVitaliy/logger"
+)
+
+type keyFlags map[string][]byte
+
+func (k keyFlags) String() string { return "" }
+
+func (k keyFlags) Set(s string) error {
+	kid, b64, ok := strings.Cut(strings.TrimSpace(s), "=")
+	if !ok || kid == "" {
+		return fmt.Errorf("key must be kid=base64, got %q", s)
+	}
+	key, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return fmt.Errorf("key %s: %w", kid, err)
+	}
+	k[kid] = key
+	return nil
+}
+
+type member struct {
+	key   string
+	value json.RawMessage
+}
+
+type decrypter struct {
+	ring   logger.KeyRing
+	line   int
+	failed int
+}
+
+func main() {
+	keys := keyFlags{}
+	flag.Var(keys, "key", "decryption key as kid=base64, may be repeated")
+	keysFile := flag.String("keys", "", "file with one kid=base64 key per line")
+	flag.Parse()
+
+	if *keysFile != "" {
+		if err := readKeysFile(*keysFile, keys); err != nil {
+			fatal(err)
+		}
+	}
+	if len(keys) == 0 {
+		fatal(errors.New("no keys given, use -key or -keys"))
+	}
+
+	d := &decrypter{ring: logger.KeyRing{Keys: keys}}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	if flag.NArg() == 0 {
+		if err := d.process(os.Stdin, out); err != nil {
+			fatal(err)
+		}
+	}
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fatal(err)
+		}
+		err = d.process(f, out)
+		f.Close()
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	if d.failed > 0 {
+		out.Flush()
+		fmt.Fprintf(os.Stderr, "logdecrypt: %d fields could not be decrypted\n", d.failed)
+		os.Exit(1)
+	}
+}
+
+func (d *decrypter) process(r io.Reader, w io.Writer) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for sc.Scan() {
+		d.line++
+		line := sc.Bytes()
+		if out, err := d.rewrite(nil, line); err == nil {
+			line = out
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// rewrite walks a JSON value keeping the member order and replaces encrypted envelopes with their plaintext.
+func (d *decrypter) rewrite(path []string, raw json.RawMessage) (json.RawMessage, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '{' {
+		return raw, nil
+	}
+
+	members, err := decodeObject(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if f, ok := envelope(members); ok && len(path) > 0 {
+		plain, err := d.ring.Decrypt(strings.Join(path, "."), f)
+		if err == nil {
+			return plain, nil
+		}
+		d.failed++
+		fmt.Fprintf(os.Stderr, "logdecrypt: line %d: %v\n", d.line, err)
+		return raw, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(m.key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := d.rewrite(append(path[:len(path):len(path)], m.key), m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func decodeObject(raw json.RawMessage) ([]member, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	var members []member
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected token %v", t)
+		}
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		members = append(members, member{key, v})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func envelope(members []member) (logger.EncryptedField, bool) {
+	var f logger.EncryptedField
+	if len(members) != 3 {
+		return f, false
+	}
+
+	seen := 0
+	for _, m := range members {
+		var err error
+		switch m.key {
+		case "kid":
+			err = json.Unmarshal(m.value, &f.KeyID)
+		case "nonce":
+			err = json.Unmarshal(m.value, &f.Nonce)
+		case "ciphertext":
+			err = json.Unmarshal(m.value, &f.Ciphertext)
+		default:
+			return f, false
+		}
+		if err != nil {
+			return f, false
+		}
+		seen++
+	}
+	return f, seen == 3
+}
+
+func readKeysFile(name string, keys keyFlags) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := keys.Set(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "logdecrypt:", err)
+	os.Exit(1)
+}
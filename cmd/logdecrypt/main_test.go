@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/FurmanovVitaliy/logger"
+)
+
+func TestDecryptRoundTrip(t *testing.T) {
+	keys := map[string][]byte{
+		"k0": bytes.Repeat([]byte{7}, 32),
+		"k1": bytes.Repeat([]byte{9}, 32),
+	}
+
+	var log bytes.Buffer
+	for _, kid := range []string{"k0", "k1"} {
+		h, err := logger.NewEncryptHandler(slog.NewJSONHandler(&log, nil), &logger.EncryptOptions{
+			Keys:    []string{"password", "card"},
+			KeyRing: logger.KeyRing{Keys: keys, Current: kid},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		slog.New(h).WithGroup("user").Info("signup", "name", "bob", "password", "hunter2",
+			"card", map[string]any{"number": "4111111111111111"})
+	}
+	log.WriteString("not json\n")
+	if strings.Contains(log.String(), "hunter2") {
+		t.Fatal("plaintext in the encrypted log")
+	}
+
+	var out bytes.Buffer
+	d := &decrypter{ring: logger.KeyRing{Keys: keys}}
+	if err := d.process(&log, &out); err != nil {
+		t.Fatal(err)
+	}
+	if d.failed != 0 {
+		t.Fatalf("%d fields failed", d.failed)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 || lines[2] != "not json" {
+		t.Fatalf("output:\n%s", out.String())
+	}
+	for _, line := range lines[:2] {
+		var m struct {
+			Msg  string `json:"msg"`
+			User struct {
+				Name     string         `json:"name"`
+				Password string         `json:"password"`
+				Card     map[string]any `json:"card"`
+			} `json:"user"`
+		}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid output line %q: %v", line, err)
+		}
+		if m.Msg != "signup" || m.User.Name != "bob" || m.User.Password != "hunter2" || m.User.Card["number"] != "4111111111111111" {
+			t.Fatalf("decrypted line = %s", line)
+		}
+	}
+	if !strings.HasPrefix(lines[0], `{"time":`) {
+		t.Fatalf("member order changed: %s", lines[0])
+	}
+}
+
+func TestDecryptMovedField(t *testing.T) {
+	keys := map[string][]byte{"k0": bytes.Repeat([]byte{7}, 32)}
+	var log bytes.Buffer
+	h, err := logger.NewEncryptHandler(slog.NewJSONHandler(&log, nil), &logger.EncryptOptions{
+		Keys:    []string{"password"},
+		KeyRing: logger.KeyRing{Keys: keys, Current: "k0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.New(h).Info("signup", "password", "hunter2")
+
+	// move the envelope to another key, its AAD no longer matches the path
+	moved := strings.Replace(log.String(), `"password":`, `"note":`, 1)
+
+	var out bytes.Buffer
+	d := &decrypter{ring: logger.KeyRing{Keys: keys}}
+	if err := d.process(strings.NewReader(moved), &out); err != nil {
+		t.Fatal(err)
+	}
+	if d.failed != 1 || strings.Contains(out.String(), "hunter2") || !strings.Contains(out.String(), `"ciphertext"`) {
+		t.Fatalf("failed = %d, output %s", d.failed, out.String())
+	}
+}
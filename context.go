@@ -2,7 +2,11 @@ package logger
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"log/slog"
+	"time"
 )
 
 // https://dev.to/ilyakaznacheev/where-to-place-logger-in-golang-13o3
@@ -22,3 +26,103 @@ func loggerFromContext(ctx context.Context) *slog.Logger {
 
 	return slog.Default()
 }
+
+type ctxRequestID struct{}
+
+// ContextWithRequestID stores a request ID, the httplog and rpclog packages read it to propagate the ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxRequestID{}, id)
+}
+
+// RequestIDFromContext returns the request ID added with ContextWithRequestID.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxRequestID{}).(string)
+	return id
+}
+
+const maxRequestIDLen = 128
+
+// ValidRequestID reports whether a request ID received from a client can be used as is:
+// 1 to 128 printable ASCII characters without spaces. Middlewares replace other IDs with
+// NewRequestID, so a client can't inject control characters or oversized values into the logs.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if c := id[i]; c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// NewRequestID returns a random 128-bit request ID in hex.
+func NewRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+type ctxAttrs struct{}
+
+// ContextWithAttrs adds attributes to context, they are appended to the ones added before.
+// Handlers wrapped with NewContextHandler add them to every record logged with this context.
+func ContextWithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	if len(attrs) == 0 {
+		return ctx
+	}
+	prev := AttrsFromContext(ctx)
+	merged := make([]slog.Attr, 0, len(prev)+len(attrs))
+	merged = append(merged, prev...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, ctxAttrs{}, merged)
+}
+
+// AttrsFromContext returns attributes added with ContextWithAttrs.
+func AttrsFromContext(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(ctxAttrs{}).([]slog.Attr)
+	return attrs
+}
+
+type contextHandler struct {
+	next slog.Handler
+}
+
+// NewContextHandler returns a handler that adds the attributes of the record context
+// (see ContextWithAttrs) to every record, they are placed in the groups opened with WithGroup
+// like the attributes of the logging call.
+func NewContextHandler(next slog.Handler) slog.Handler {
+	return &contextHandler{next: next}
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := AttrsFromContext(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &contextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &contextHandler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"req-1", true},
+		{"4bf92f3577b34da6a3ce929d0e0e4736", true},
+		{"a/b:c=d", true},
+		{strings.Repeat("x", 128), true},
+		{strings.Repeat("x", 129), false},
+		{"", false},
+		{"bad id", false},
+		{"line\nbreak", false},
+		{"tab\t", false},
+		{"naïve", false},
+		{"\x7f", false},
+	}
+	for _, tt := range tests {
+		if got := ValidRequestID(tt.id); got != tt.want {
+			t.Errorf("ValidRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+
+	if id := NewRequestID(); len(id) != 32 || !ValidRequestID(id) {
+		t.Fatalf("NewRequestID() = %q", id)
+	}
+}
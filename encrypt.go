@@ -0,0 +1,150 @@
+package logger
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// KeyRing holds AES keys (16, 24 or 32 bytes) by key ID, new values are encrypted with Keys[Current].
+// Rotated keys stay in the ring so older logs can still be decrypted.
+type KeyRing struct {
+	Keys    map[string][]byte
+	Current string
+}
+
+// EncryptedField is the envelope an encrypted attribute is replaced with.
+// The plaintext is the JSON encoding of the original value and the attribute path
+// is bound to the ciphertext as additional data, so a field can't be moved to another key.
+type EncryptedField struct {
+	KeyID      string `json:"kid"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type EncryptOptions struct {
+	// Keys lists the attributes to encrypt, with the same path syntax as RedactRule.Key.
+	Keys    []string
+	KeyRing KeyRing
+}
+
+// FieldEncryptor encrypts selected attribute values with AES-GCM.
+// Its ReplaceAttr method can be used in HandlerOptions directly.
+type FieldEncryptor struct {
+	keys [][]string
+	kid  string
+	aead cipher.AEAD
+}
+
+var ErrNotEncryptedField = errors.New("value is not an encrypted field")
+
+func NewFieldEncryptor(opts *EncryptOptions) (*FieldEncryptor, error) {
+	if opts == nil {
+		return nil, ErrUnknownKeyID
+	}
+	aead, err := opts.KeyRing.aead(opts.KeyRing.Current)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &FieldEncryptor{kid: opts.KeyRing.Current, aead: aead}
+	for _, k := range opts.Keys {
+		e.keys = append(e.keys, splitKeyPattern(k))
+	}
+	return e, nil
+}
+
+// NewEncryptHandler returns a handler that encrypts attributes before passing records to next.
+func NewEncryptHandler(next Handler, opts *EncryptOptions) (Handler, error) {
+	e, err := NewFieldEncryptor(opts)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplaceAttrHandler(next, e.ReplaceAttr), nil
+}
+
+func (e *FieldEncryptor) ReplaceAttr(groups []string, a Attr) Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+
+	path := append(groups[:len(groups):len(groups)], a.Key)
+	for _, k := range e.keys {
+		if matchKeyPattern(k, path) {
+			return e.encryptAttr(strings.Join(path, "."), a)
+		}
+	}
+	return a
+}
+
+func (e *FieldEncryptor) encryptAttr(path string, a Attr) Attr {
+	plain, err := encryptPlaintext(a.Value.Resolve().Any())
+	if err != nil {
+		// never fall back to the plaintext
+		return slog.String(a.Key, defaultRedactMask)
+	}
+
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return slog.String(a.Key, defaultRedactMask)
+	}
+	ct := e.aead.Seal(nil, nonce, plain, []byte(path))
+
+	return slog.Group(a.Key,
+		slog.String("kid", e.kid),
+		slog.String("nonce", base64.StdEncoding.EncodeToString(nonce)),
+		slog.String("ciphertext", base64.StdEncoding.EncodeToString(ct)),
+	)
+}
+
+// encryptPlaintext returns the JSON encoding of v. Errors are encoded by their message and values
+// that encode to {} without being a map, such as types with only unexported fields, by fmt.Sprint,
+// so the ciphertext never silently holds no data.
+func encryptPlaintext(v any) ([]byte, error) {
+	if err, ok := v.(error); ok {
+		return json.Marshal(err.Error())
+	}
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if string(plain) == "{}" && reflect.Indirect(reflect.ValueOf(v)).Kind() != reflect.Map {
+		return json.Marshal(fmt.Sprint(v))
+	}
+	return plain, nil
+}
+
+// Decrypt returns the JSON encoding of the original value, path is the dot-separated attribute path.
+func (k KeyRing) Decrypt(path string, f EncryptedField) ([]byte, error) {
+	aead, err := k.aead(f.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != aead.NonceSize() {
+		return nil, ErrNotEncryptedField
+	}
+	plain, err := aead.Open(nil, f.Nonce, f.Ciphertext, []byte(path))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s: %w", path, err)
+	}
+	return plain, nil
+}
+
+func (k KeyRing) aead(kid string) (cipher.AEAD, error) {
+	key, ok := k.Keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, kid)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,183 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func testKeyRing(current string) KeyRing {
+	return KeyRing{
+		Keys: map[string][]byte{
+			"k0": bytes.Repeat([]byte{0}, 32),
+			"k1": bytes.Repeat([]byte{1}, 16),
+		},
+		Current: current,
+	}
+}
+
+// encryptedLine logs one record through an encrypt handler and returns the decoded line.
+func encryptedLine(t *testing.T, ring KeyRing, log func(*slog.Logger)) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	h, err := NewEncryptHandler(slog.NewJSONHandler(&buf, nil), &EncryptOptions{
+		Keys:    []string{"password", "card.number", "payload"},
+		KeyRing: ring,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log(slog.New(h))
+	return decodeJSONLine(t, &buf)
+}
+
+// field converts the decoded envelope at m[key] back to an EncryptedField.
+func field(t *testing.T, m map[string]any, key string) EncryptedField {
+	t.Helper()
+	b, err := json.Marshal(m[key])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f EncryptedField
+	if err := json.Unmarshal(b, &f); err != nil || len(f.Ciphertext) == 0 {
+		t.Fatalf("%s = %v is not an envelope: %v", key, m[key], err)
+	}
+	return f
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	ring := testKeyRing("k1")
+	m := encryptedLine(t, ring, func(l *slog.Logger) {
+		l.Info("signup", "user", "bob", "password", "hunter2",
+			slog.Group("card", "number", "4111111111111111", "brand", "visa"))
+	})
+
+	if m["user"] != "bob" || strings.Contains(mustJSON(t, m), "hunter2") {
+		t.Fatalf("line = %v", m)
+	}
+	f := field(t, m, "password")
+	if f.KeyID != "k1" {
+		t.Fatalf("kid = %q", f.KeyID)
+	}
+	plain, err := ring.Decrypt("password", f)
+	if err != nil || string(plain) != `"hunter2"` {
+		t.Fatalf("Decrypt = %s, %v", plain, err)
+	}
+
+	card := m["card"].(map[string]any)
+	if card["brand"] != "visa" {
+		t.Fatalf("card = %v", card)
+	}
+	plain, err = ring.Decrypt("card.number", field(t, card, "number"))
+	if err != nil || string(plain) != `"4111111111111111"` {
+		t.Fatalf("Decrypt = %s, %v", plain, err)
+	}
+}
+
+func TestEncryptBindsPath(t *testing.T) {
+	ring := testKeyRing("k0")
+	m := encryptedLine(t, ring, func(l *slog.Logger) {
+		l.Info("signup", "password", "hunter2")
+	})
+	f := field(t, m, "password")
+
+	for _, path := range []string{"payload", "user.password", ""} {
+		if _, err := ring.Decrypt(path, f); err == nil {
+			t.Errorf("ciphertext of password decrypted as %q", path)
+		}
+	}
+
+	f.Nonce[0] ^= 1
+	if _, err := ring.Decrypt("password", f); err == nil {
+		t.Error("modified nonce accepted")
+	}
+	f.Nonce = f.Nonce[:4]
+	if _, err := ring.Decrypt("password", f); !errors.Is(err, ErrNotEncryptedField) {
+		t.Errorf("short nonce: %v", err)
+	}
+}
+
+func TestEncryptKeyRotation(t *testing.T) {
+	old := encryptedLine(t, testKeyRing("k0"), func(l *slog.Logger) {
+		l.Info("a", "password", "old")
+	})
+	rotated := testKeyRing("k1")
+	cur := encryptedLine(t, rotated, func(l *slog.Logger) {
+		l.Info("b", "password", "new")
+	})
+
+	for _, tt := range []struct {
+		m    map[string]any
+		kid  string
+		want string
+	}{{old, "k0", `"old"`}, {cur, "k1", `"new"`}} {
+		f := field(t, tt.m, "password")
+		if f.KeyID != tt.kid {
+			t.Fatalf("kid = %q, want %q", f.KeyID, tt.kid)
+		}
+		if plain, err := rotated.Decrypt("password", f); err != nil || string(plain) != tt.want {
+			t.Fatalf("Decrypt = %s, %v", plain, err)
+		}
+	}
+
+	retired := KeyRing{Keys: map[string][]byte{"k1": rotated.Keys["k1"]}}
+	if _, err := retired.Decrypt("password", field(t, old, "password")); !errors.Is(err, ErrUnknownKeyID) {
+		t.Fatalf("retired key: %v", err)
+	}
+	if _, err := NewFieldEncryptor(&EncryptOptions{KeyRing: KeyRing{Keys: rotated.Keys, Current: "k9"}}); !errors.Is(err, ErrUnknownKeyID) {
+		t.Fatalf("unknown current key: %v", err)
+	}
+}
+
+type opaque struct {
+	id int
+}
+
+func (o opaque) String() string {
+	return "opaque"
+}
+
+func TestEncryptPlaintext(t *testing.T) {
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{"s", `"s"`},
+		{42, `42`},
+		{errors.New("boom"), `"boom"`},
+		{opaque{1}, `"opaque"`},
+		{&opaque{1}, `"opaque"`},
+		{map[string]int{}, `{}`},
+		{map[string]int{"a": 1}, `{"a":1}`},
+		{[]string{"x"}, `["x"]`},
+		{nil, `null`},
+	}
+	for _, tt := range tests {
+		got, err := encryptPlaintext(tt.in)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("encryptPlaintext(%#v) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+
+	if _, err := encryptPlaintext(func() {}); err == nil {
+		t.Error("func encoded")
+	}
+	m := encryptedLine(t, testKeyRing("k0"), func(l *slog.Logger) {
+		l.Info("x", "payload", func() {})
+	})
+	if m["payload"] != defaultRedactMask {
+		t.Fatalf("unencodable value = %v, want the mask", m["payload"])
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// flattenAttr resolves a and calls fn for every leaf with its dot-qualified key.
+// Empty attrs and empty groups are skipped, inline groups (empty key) are merged into the parent.
+func flattenAttr(prefix []string, a slog.Attr, fn func(key string, v slog.Value)) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() != slog.KindGroup {
+		fn(joinKey(prefix, a.Key), a.Value)
+		return
+	}
+
+	if a.Key != "" {
+		prefix = append(prefix[:len(prefix):len(prefix)], a.Key)
+	}
+	for _, ga := range a.Value.Group() {
+		flattenAttr(prefix, ga, fn)
+	}
+}
+
+func joinKey(prefix []string, key string) string {
+	if len(prefix) == 0 {
+		return key
+	}
+	return strings.Join(prefix, ".") + "." + key
+}
@@ -0,0 +1,252 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	gcpSourceKey  = "logging.googleapis.com/sourceLocation"
+	gcpTraceKey   = "logging.googleapis.com/trace"
+	gcpSpanKey    = "logging.googleapis.com/spanId"
+	gcpSampledKey = "logging.googleapis.com/trace_sampled"
+	gcpHTTPKey    = "httpRequest"
+)
+
+// TraceExtractor returns the trace of the current request, ok is false if there is none.
+type TraceExtractor func(ctx context.Context) (traceID, spanID string, sampled, ok bool)
+
+type GCPOptions struct {
+	Level       slog.Leveler
+	AddSource   bool
+	ReplaceAttr ReplaceAttrFunc
+
+	// ProjectID qualifies trace IDs as projects/<id>/traces/<trace>, GOOGLE_CLOUD_PROJECT is used if empty.
+	ProjectID string
+	// Trace reads the trace from the record context, TraceFromContext is used if nil so OpenTelemetry
+	// spans and ContextWithTraceparent are picked up without configuration.
+	Trace TraceExtractor
+}
+
+type gcpHandler struct {
+	scope     rootScope
+	projectID string
+	trace     TraceExtractor
+}
+
+// NewGCPHandler returns a JSON handler whose output is understood by the Cloud Logging agent
+// on Cloud Run and GKE: level is written as severity, source as sourceLocation,
+// the context trace as trace/spanId/trace_sampled and an "httpRequest" group is shaped as HttpRequest.
+// The trace and "httpRequest" groups passed to the logging call or to With are written at the top
+// level of the entry also when the logger has open groups, Cloud Logging reads them only there.
+func NewGCPHandler(w io.Writer, opts *GCPOptions) Handler {
+	if opts == nil {
+		opts = &GCPOptions{}
+	}
+
+	json := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       opts.Level,
+		AddSource:   opts.AddSource,
+		ReplaceAttr: ChainReplaceAttr(opts.ReplaceAttr, gcpReplaceAttr),
+	})
+
+	h := &gcpHandler{
+		scope:     newRootScope(json),
+		projectID: opts.ProjectID,
+		trace:     opts.Trace,
+	}
+	if h.trace == nil {
+		h.trace = contextTrace
+	}
+	if h.projectID == "" {
+		h.projectID = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	return h
+}
+
+func (h *gcpHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.scope.next.Enabled(ctx, level)
+}
+
+func (h *gcpHandler) Handle(ctx context.Context, r slog.Record) error {
+	var extra []slog.Attr
+	if traceID, spanID, sampled, ok := h.trace(ctx); ok {
+		if h.projectID != "" {
+			traceID = "projects/" + h.projectID + "/traces/" + traceID
+		}
+		extra = append(extra, slog.String(gcpTraceKey, traceID))
+		if spanID != "" {
+			extra = append(extra, slog.String(gcpSpanKey, spanID))
+		}
+		extra = append(extra, slog.Bool(gcpSampledKey, sampled))
+	}
+
+	if len(h.scope.goas) > 0 && hasHTTPRequest(r) {
+		nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+		r.Attrs(func(a slog.Attr) bool {
+			if isHTTPRequest(a) {
+				extra = append(extra, a)
+			} else {
+				nr.AddAttrs(a)
+			}
+			return true
+		})
+		r = nr
+	}
+	return h.scope.handle(ctx, r, extra)
+}
+
+func (h *gcpHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	if len(h.scope.goas) == 0 || !slices.ContainsFunc(attrs, isHTTPRequest) {
+		h2.scope = h.scope.withAttrs(attrs)
+		return &h2
+	}
+
+	var root, rest []slog.Attr
+	for _, a := range attrs {
+		if isHTTPRequest(a) {
+			root = append(root, a)
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	h2.scope = h.scope.withRootAttrs(root)
+	if len(rest) > 0 {
+		h2.scope = h2.scope.withAttrs(rest)
+	}
+	return &h2
+}
+
+func (h *gcpHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.withGroup(name)
+	return &h2
+}
+
+func hasHTTPRequest(r slog.Record) bool {
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = isHTTPRequest(a)
+		return !found
+	})
+	return found
+}
+
+func isHTTPRequest(a slog.Attr) bool {
+	return a.Key == gcpHTTPKey && a.Value.Kind() == slog.KindGroup
+}
+
+// GCPSeverity maps a level to a Cloud Logging severity, custom levels between the
+// standard ones are rounded down: LevelNotice is NOTICE, LevelCritical is CRITICAL and so on.
+func GCPSeverity(l Level) string {
+	switch {
+	case l < LevelInfo:
+		return "DEBUG"
+	case l < LevelNotice:
+		return "INFO"
+	case l < LevelWarn:
+		return "NOTICE"
+	case l < LevelError:
+		return "WARNING"
+	case l < LevelCritical:
+		return "ERROR"
+	case l < LevelAlert:
+		return "CRITICAL"
+	case l < LevelEmergency:
+		return "ALERT"
+	default:
+		return "EMERGENCY"
+	}
+}
+
+// HTTPRequestAttr builds the "httpRequest" attribute of a served request.
+func HTTPRequestAttr(r *http.Request, status int, responseSize int64, latency time.Duration) Attr {
+	return slog.Group(gcpHTTPKey,
+		slog.String("requestMethod", r.Method),
+		slog.String("requestUrl", r.URL.String()),
+		slog.Int("status", status),
+		slog.String("responseSize", strconv.FormatInt(responseSize, 10)),
+		slog.String("userAgent", r.UserAgent()),
+		slog.String("remoteIp", r.RemoteAddr),
+		slog.String("referer", r.Referer()),
+		slog.String("protocol", r.Proto),
+		slog.String("latency", gcpDuration(latency)),
+	)
+}
+
+var gcpHTTPKeys = map[string]string{
+	"method":        "requestMethod",
+	"url":           "requestUrl",
+	"size":          "responseSize",
+	"bytes":         "responseSize",
+	"response_size": "responseSize",
+	"request_size":  "requestSize",
+	"user_agent":    "userAgent",
+	"ip":            "remoteIp",
+	"client_ip":     "remoteIp",
+	"remote_ip":     "remoteIp",
+	"server_ip":     "serverIp",
+	"duration":      "latency",
+}
+
+func gcpReplaceAttr(groups []string, a Attr) Attr {
+	if len(groups) > 0 && groups[len(groups)-1] == gcpHTTPKey {
+		return gcpHTTPRequestField(a)
+	}
+	if len(groups) > 0 {
+		return a
+	}
+
+	switch a.Key {
+	case slog.LevelKey:
+		if l, ok := a.Value.Any().(slog.Level); ok {
+			return slog.String("severity", GCPSeverity(l))
+		}
+	case slog.MessageKey:
+		a.Key = "message"
+	case slog.SourceKey:
+		if s, ok := a.Value.Any().(*slog.Source); ok {
+			return slog.Group(gcpSourceKey,
+				slog.String("file", s.File),
+				slog.String("line", strconv.Itoa(s.Line)),
+				slog.String("function", s.Function),
+			)
+		}
+	}
+	return a
+}
+
+func gcpHTTPRequestField(a Attr) Attr {
+	if k, ok := gcpHTTPKeys[a.Key]; ok {
+		a.Key = k
+	}
+
+	switch a.Key {
+	case "latency":
+		if a.Value.Kind() == slog.KindDuration {
+			a.Value = slog.StringValue(gcpDuration(a.Value.Duration()))
+		}
+	case "responseSize", "requestSize":
+		// int64 fields are strings in the LogEntry JSON
+		a.Value = slog.StringValue(a.Value.String())
+	}
+	return a
+}
+
+func gcpDuration(d time.Duration) string {
+	return fmt.Sprintf("%.9fs", d.Seconds())
+}
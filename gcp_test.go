@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGCPSeverity(t *testing.T) {
+	tests := []struct {
+		level Level
+		want  string
+	}{
+		{LevelDebug, "DEBUG"},
+		{LevelInfo, "INFO"},
+		{LevelNotice, "NOTICE"},
+		{LevelWarn, "WARNING"},
+		{LevelError, "ERROR"},
+		{LevelCritical, "CRITICAL"},
+		{LevelAlert, "ALERT"},
+		{LevelEmergency, "EMERGENCY"},
+		{LevelInfo + 1, "INFO"},
+		{LevelDebug - 4, "DEBUG"},
+	}
+	for _, tt := range tests {
+		if got := GCPSeverity(tt.level); got != tt.want {
+			t.Errorf("GCPSeverity(%v) = %s, want %s", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestGCPHandlerEntry(t *testing.T) {
+	tc, _ := ParseTraceparent(testTraceparent)
+	ctx := ContextWithTraceparent(context.Background(), tc)
+
+	var buf bytes.Buffer
+	l := slog.New(NewGCPHandler(&buf, &GCPOptions{AddSource: true, ProjectID: "demo"}))
+	l.Log(ctx, LevelNotice, "started")
+
+	m := decodeJSONLine(t, &buf)
+	if m["severity"] != "NOTICE" || m["message"] != "started" || m["level"] != nil || m["msg"] != nil {
+		t.Fatalf("entry = %v", m)
+	}
+	if m[gcpTraceKey] != "projects/demo/traces/"+tc.TraceIDString() || m[gcpSpanKey] != tc.SpanIDString() || m[gcpSampledKey] != true {
+		t.Fatalf("trace fields = %v", m)
+	}
+	src, _ := m[gcpSourceKey].(map[string]any)
+	if src["file"] == nil || src["line"] == nil || src["function"] == nil {
+		t.Fatalf("sourceLocation = %v", m[gcpSourceKey])
+	}
+
+	l.Info("untraced")
+	if m = decodeJSONLine(t, &buf); m[gcpTraceKey] != nil {
+		t.Fatalf("trace without a context trace: %v", m)
+	}
+}
+
+func TestGCPHandlerHTTPRequestAtRoot(t *testing.T) {
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+	tc, _ := ParseTraceparent(testTraceparent)
+	ctx := ContextWithTraceparent(context.Background(), tc)
+	req := httptest.NewRequest("GET", "/users?id=1", nil)
+
+	var buf bytes.Buffer
+	l := slog.New(NewGCPHandler(&buf, nil))
+
+	check := func(name string, m map[string]any, status float64) {
+		t.Helper()
+		hr, ok := m[gcpHTTPKey].(map[string]any)
+		if !ok {
+			t.Fatalf("%s: httpRequest not at the root: %v", name, m)
+		}
+		if hr["requestMethod"] != "GET" || hr["status"] != status || hr["latency"] != "0.250000000s" || hr["responseSize"] != "512" {
+			t.Fatalf("%s: httpRequest = %v", name, hr)
+		}
+		if m[gcpTraceKey] != tc.TraceIDString() {
+			t.Fatalf("%s: trace not at the root: %v", name, m)
+		}
+		grp, _ := m["req"].(map[string]any)
+		if grp == nil || grp["user"] != "bob" || grp[gcpHTTPKey] != nil {
+			t.Fatalf("%s: group = %v", name, m["req"])
+		}
+	}
+
+	l.WithGroup("req").InfoContext(ctx, "served", "user", "bob", HTTPRequestAttr(req, 200, 512, 250*time.Millisecond))
+	check("record", decodeJSONLine(t, &buf), 200)
+
+	l.WithGroup("req").With(
+		slog.Group(gcpHTTPKey, "method", "GET", "status", 404, "bytes", 512, "duration", 250*time.Millisecond),
+		"user", "bob",
+	).InfoContext(ctx, "served")
+	check("With", decodeJSONLine(t, &buf), 404)
+}
@@ -3,14 +3,18 @@ module github.com/FurmanovVitaliy/logger
 go 1.23.2
 
 require (
-	github.com/fatih/color v1.18.0
 	github.com/mattn/go-runewidth v0.0.16
-	golang.org/x/term v0.26.0
+	go.opentelemetry.io/otel/trace v1.34.0
+	golang.org/x/sys v0.28.0
+	golang.org/x/term v0.27.0
+	google.golang.org/grpc v1.70.0
+	google.golang.org/protobuf v1.35.2
 )
 
 require (
-	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
-	golang.org/x/sys v0.27.0 // indirect
+	go.opentelemetry.io/otel v1.34.0 // indirect
+	golang.org/x/net v0.32.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20241202173237-19429a94021a // indirect
 )
@@ -0,0 +1,322 @@
+// Package httplog provides net/http middleware and client transport that log through the logger package.
+package httplog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/FurmanovVitaliy/logger"
+)
+
+const (
+	RequestIDHeader   = "X-Request-ID"
+	TraceparentHeader = "Traceparent"
+)
+
+type Options struct {
+	// RouteLevels overrides the access log level of successful and client error responses
+	// by ServeMux pattern or path, for example {"GET /healthz": logger.LevelDebug}. 5xx responses are always Error.
+	RouteLevels map[string]logger.Level
+
+	// RequestHeaders and ResponseHeaders list the headers to capture.
+	RequestHeaders  []string
+	ResponseHeaders []string
+	// MaxBodyBytes captures request and response bodies up to the limit, zero disables capture.
+	MaxBodyBytes int
+	// Redactor is applied to captured headers and bodies, logger.NewRedactor(nil) is used if nil.
+	Redactor *logger.Redactor
+
+	// TrustProxy takes the client IP from X-Forwarded-For and X-Real-IP.
+	TrustProxy bool
+	// RequestID generates IDs for requests without a valid X-Request-ID header.
+	RequestID func() string
+}
+
+// RequestIDFromContext returns the request ID set by Middleware, it is logger.RequestIDFromContext.
+func RequestIDFromContext(ctx context.Context) string {
+	return logger.RequestIDFromContext(ctx)
+}
+
+// ContextWithRequestID stores a request ID, the Transport propagates it to outgoing requests.
+// It is logger.ContextWithRequestID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return logger.ContextWithRequestID(ctx, id)
+}
+
+// Middleware propagates or generates X-Request-ID, stores a logger with the request ID in the request context,
+// recovers panics and writes an access log record per request. If log is nil the logger from the request context is used.
+func Middleware(log *logger.Logger, opts *Options) func(http.Handler) http.Handler {
+	if opts == nil {
+		opts = &Options{}
+	}
+	o := *opts
+	if o.Redactor == nil {
+		o.Redactor = logger.NewRedactor(nil)
+	}
+	if o.RequestID == nil {
+		o.RequestID = logger.NewRequestID
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx := r.Context()
+
+			id := r.Header.Get(RequestIDHeader)
+			if !logger.ValidRequestID(id) {
+				id = o.RequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			if _, ok := logger.TraceFromContext(ctx); !ok {
+				if tc, err := logger.ParseTraceparent(r.Header.Get(TraceparentHeader)); err == nil {
+					ctx = logger.ContextWithTraceparent(ctx, tc)
+				}
+			}
+
+			base := log
+			if base == nil {
+				base = logger.ExtractLogger(ctx)
+			}
+			reqLog := base.With(slog.String("request_id", id))
+
+			ctx = ContextWithRequestID(ctx, id)
+			ctx = logger.ContextWithLogger(ctx, reqLog)
+			r = r.WithContext(ctx)
+
+			var reqBody *capture
+			if o.MaxBodyBytes > 0 && r.Body != nil && r.Body != http.NoBody {
+				reqBody = &capture{limit: o.MaxBodyBytes}
+				r.Body = &teeBody{ReadCloser: r.Body, capture: reqBody}
+			}
+
+			rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+			if o.MaxBodyBytes > 0 {
+				rw.body = &capture{limit: o.MaxBodyBytes}
+			}
+
+			defer func() {
+				p := recover()
+				if p != nil {
+					if p == http.ErrAbortHandler {
+						panic(p)
+					}
+					reqLog.LogAttrs(ctx, logger.LevelError, "panic recovered",
+						slog.Any("panic", p),
+						slog.String("stack", string(debug.Stack())),
+					)
+					// the status already sent to the client is kept in the access log
+					if !rw.wroteHeader {
+						rw.WriteHeader(http.StatusInternalServerError)
+					}
+				}
+
+				o.accessLog(ctx, reqLog, r, rw, reqBody, time.Since(start), p != nil)
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// accessLog writes the record of a request, panicked marks requests whose handler panicked,
+// they are logged as errors whatever status was sent.
+func (o *Options) accessLog(ctx context.Context, log *logger.Logger, r *http.Request, rw *responseWriter, reqBody *capture, d time.Duration, panicked bool) {
+	route := r.Pattern
+	if route == "" {
+		route = r.URL.Path
+	}
+
+	level := o.level(r, route, rw.status)
+	if panicked {
+		level = logger.LevelError
+	}
+	if !log.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("route", route),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", rw.status),
+		slog.Int64("bytes", rw.bytes),
+		slog.Duration("duration", d),
+		slog.String("client_ip", clientIP(r, o.TrustProxy)),
+	}
+	if panicked {
+		attrs = append(attrs, slog.Bool("panic", true))
+	}
+
+	if a := o.headers("request_headers", r.Header, o.RequestHeaders); a.Key != "" {
+		attrs = append(attrs, a)
+	}
+	if a := o.headers("response_headers", rw.Header(), o.ResponseHeaders); a.Key != "" {
+		attrs = append(attrs, a)
+	}
+	if reqBody != nil && reqBody.buf.Len() > 0 {
+		attrs = append(attrs, o.body("request_body", reqBody))
+	}
+	if rw.body != nil && rw.body.buf.Len() > 0 {
+		attrs = append(attrs, o.body("response_body", rw.body))
+	}
+
+	log.LogAttrs(ctx, level, "http request", attrs...)
+}
+
+func (o *Options) level(r *http.Request, route string, status int) logger.Level {
+	if status >= 500 {
+		return logger.LevelError
+	}
+	if l, ok := o.RouteLevels[route]; ok {
+		return l
+	}
+	if l, ok := o.RouteLevels[r.URL.Path]; ok {
+		return l
+	}
+	if status >= 400 {
+		return logger.LevelWarn
+	}
+	return logger.LevelInfo
+}
+
+func (o *Options) headers(key string, h http.Header, names []string) slog.Attr {
+	if len(names) == 0 {
+		return slog.Attr{}
+	}
+	attrs := make([]slog.Attr, 0, len(names))
+	for _, name := range names {
+		values := h.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		a := o.Redactor.ReplaceAttr([]string{key}, slog.String(strings.ToLower(name), strings.Join(values, ", ")))
+		if a.Key != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	if len(attrs) == 0 {
+		return slog.Attr{}
+	}
+	return slog.Attr{Key: key, Value: slog.GroupValue(attrs...)}
+}
+
+func (o *Options) body(key string, c *capture) slog.Attr {
+	s := o.Redactor.Redact(c.buf.String())
+	if c.truncated {
+		s += "…"
+	}
+	return slog.String(key, s)
+}
+
+/*--------------------------------RESPONSE WRITER--------------------------------------------*/
+
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+	body        *capture
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	if w.body != nil {
+		w.body.Write(b[:n])
+	}
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		// flushing sends the headers with the current status
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("httplog: response writer does not implement http.Hijacker")
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+/*--------------------------------BODY CAPTURE-----------------------------------------------*/
+
+type capture struct {
+	buf       strings.Builder
+	limit     int
+	truncated bool
+}
+
+func (c *capture) Write(b []byte) {
+	if c.truncated {
+		return
+	}
+	if room := c.limit - c.buf.Len(); room < len(b) {
+		c.truncated = true
+		// cut on a rune boundary so the logged body stays valid UTF-8
+		room = max(room, 0)
+		for room > 0 && !utf8.RuneStart(b[room]) {
+			room--
+		}
+		b = b[:room]
+	}
+	c.buf.Write(b)
+}
+
+type teeBody struct {
+	io.ReadCloser
+	capture *capture
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	t.capture.Write(p[:n])
+	return n, err
+}
+
+/*--------------------------------UTILS------------------------------------------------------*/
+
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			return strings.TrimSpace(first)
+		}
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
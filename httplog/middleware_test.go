@@ -0,0 +1,250 @@
+package httplog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FurmanovVitaliy/logger"
+)
+
+// newLog returns a debug logger writing JSON lines to the buffer.
+func newLog() (*logger.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	return logger.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: logger.LevelDebug})), &buf
+}
+
+// records returns the decoded lines with the message msg.
+func records(t *testing.T, buf *bytes.Buffer, msg string) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if m["msg"] == msg {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// serve runs one request through the middleware and returns the recorded response.
+func serve(h http.Handler, opts *Options, log *logger.Logger, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	Middleware(log, opts)(h).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	opts := &Options{RequestID: func() string { return "generated" }}
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "generated"},
+		{"req-42", "req-42"},
+		{"bad id", "generated"},
+		{"id\x01", "generated"},
+		{strings.Repeat("a", 128), strings.Repeat("a", 128)},
+		{strings.Repeat("a", 129), "generated"},
+	}
+	for _, tt := range tests {
+		log, buf := newLog()
+		var seen string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = RequestIDFromContext(r.Context())
+			logger.ExtractLogger(r.Context()).Info("inside")
+		})
+
+		req := httptest.NewRequest("GET", "/users", nil)
+		if tt.header != "" {
+			req.Header.Set(RequestIDHeader, tt.header)
+		}
+		rec := serve(h, opts, log, req)
+
+		if seen != tt.want || rec.Header().Get(RequestIDHeader) != tt.want {
+			t.Errorf("header %q: context %q, response %q, want %q", tt.header, seen, rec.Header().Get(RequestIDHeader), tt.want)
+			continue
+		}
+		inside := records(t, buf, "inside")
+		access := records(t, buf, "http request")
+		if len(inside) != 1 || inside[0]["request_id"] != tt.want || len(access) != 1 || access[0]["request_id"] != tt.want {
+			t.Errorf("header %q: records %v %v", tt.header, inside, access)
+		}
+	}
+}
+
+func TestMiddlewareTraceparent(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var got logger.TraceContext
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = logger.TraceFromContext(r.Context())
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(TraceparentHeader, tp)
+	log, _ := newLog()
+	serve(h, nil, log, req)
+	if got.String() != tp {
+		t.Fatalf("trace = %s, want %s", got, tp)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote string
+		header map[string]string
+		trust  bool
+		want   string
+	}{
+		{"remote addr", "192.0.2.1:1234", nil, false, "192.0.2.1"},
+		{"remote addr without port", "192.0.2.1", nil, false, "192.0.2.1"},
+		{"untrusted forwarded for", "192.0.2.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.7"}, false, "192.0.2.1"},
+		{"forwarded for", "192.0.2.1:1234", map[string]string{"X-Forwarded-For": " 203.0.113.7 , 10.0.0.1"}, true, "203.0.113.7"},
+		{"real ip", "192.0.2.1:1234", map[string]string{"X-Real-IP": "203.0.113.8"}, true, "203.0.113.8"},
+		{"forwarded for first", "192.0.2.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Real-IP": "203.0.113.8"}, true, "203.0.113.7"},
+		{"trusted without headers", "[2001:db8::1]:443", nil, true, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+		if got := clientIP(req, tt.trust); got != tt.want {
+			t.Errorf("%s: clientIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMiddlewareCapturesBodies(t *testing.T) {
+	log, buf := newLog()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "sid=secret")
+		w.Write([]byte("ok "))
+		w.Write(body)
+	})
+	opts := &Options{
+		MaxBodyBytes:    17,
+		RequestHeaders:  []string{"Authorization", "Content-Type"},
+		ResponseHeaders: []string{"Set-Cookie"},
+	}
+
+	req := httptest.NewRequest("POST", "/echo", strings.NewReader("mail bob@x.io ééé tail"))
+	req.Header.Set("Authorization", "Bearer abc")
+	req.Header.Set("Content-Type", "text/plain")
+	rec := serve(h, opts, log, req)
+	if rec.Body.String() != "ok mail bob@x.io ééé tail" {
+		t.Fatalf("response = %q, capture changed the body", rec.Body.String())
+	}
+
+	access := records(t, buf, "http request")
+	if len(access) != 1 {
+		t.Fatalf("records = %v", access)
+	}
+	a := access[0]
+	// 17 bytes end inside the second é, the cut backs off to its start
+	if a["request_body"] != "mail [REDACTED] é…" {
+		t.Fatalf("request_body = %q", a["request_body"])
+	}
+	if a["response_body"] != "ok mail [REDACTED] …" {
+		t.Fatalf("response_body = %q", a["response_body"])
+	}
+	if a["bytes"] != float64(len(rec.Body.String())) {
+		t.Fatalf("bytes = %v", a["bytes"])
+	}
+	reqHeaders := a["request_headers"].(map[string]any)
+	if reqHeaders["authorization"] != "[REDACTED]" || reqHeaders["content-type"] != "text/plain" {
+		t.Fatalf("request_headers = %v", reqHeaders)
+	}
+	if respHeaders := a["response_headers"].(map[string]any); respHeaders["set-cookie"] != "[REDACTED]" {
+		t.Fatalf("response_headers = %v", respHeaders)
+	}
+}
+
+func TestMiddlewareLevels(t *testing.T) {
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/users", 200, "INFO"},
+		{"/users", 404, "WARN"},
+		{"/users", 503, "ERROR"},
+		{"/healthz", 200, "DEBUG"},
+		{"/healthz", 500, "ERROR"},
+	}
+	opts := &Options{RouteLevels: map[string]logger.Level{"/healthz": logger.LevelDebug}}
+	for _, tt := range tests {
+		log, buf := newLog()
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		})
+		serve(h, opts, log, httptest.NewRequest("GET", tt.path, nil))
+		access := records(t, buf, "http request")
+		if len(access) != 1 || access[0]["level"] != tt.want || access[0]["status"] != float64(tt.status) {
+			t.Errorf("%s %d: records %v, want level %s", tt.path, tt.status, access, tt.want)
+		}
+	}
+}
+
+func TestMiddlewareRecoversPanic(t *testing.T) {
+	tests := []struct {
+		name   string
+		before func(w http.ResponseWriter)
+		status int
+	}{
+		{"before the header", func(http.ResponseWriter) {}, http.StatusInternalServerError},
+		{"after the header", func(w http.ResponseWriter) { w.WriteHeader(http.StatusAccepted) }, http.StatusAccepted},
+		{"after the body", func(w http.ResponseWriter) { w.Write([]byte("partial")) }, http.StatusOK},
+	}
+	for _, tt := range tests {
+		log, buf := newLog()
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tt.before(w)
+			panic("boom")
+		})
+		rec := serve(h, nil, log, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: client got %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		panics := records(t, buf, "panic recovered")
+		if len(panics) != 1 || panics[0]["panic"] != "boom" || !strings.Contains(panics[0]["stack"].(string), "TestMiddlewareRecoversPanic") {
+			t.Errorf("%s: panic records %v", tt.name, panics)
+		}
+		access := records(t, buf, "http request")
+		if len(access) != 1 || access[0]["level"] != "ERROR" || access[0]["panic"] != true || access[0]["status"] != float64(tt.status) {
+			t.Errorf("%s: access records %v", tt.name, access)
+		}
+	}
+}
+
+func TestMiddlewareRepanicsAbortHandler(t *testing.T) {
+	log, buf := newLog()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", p)
+		}
+		if recs := records(t, buf, "panic recovered"); len(recs) != 0 {
+			t.Fatalf("ErrAbortHandler logged as a panic: %v", recs)
+		}
+	}()
+	serve(h, nil, log, httptest.NewRequest("GET", "/", nil))
+	t.Fatal("ErrAbortHandler was swallowed")
+}
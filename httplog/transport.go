@@ -0,0 +1,229 @@
+package httplog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/FurmanovVitaliy/logger"
+)
+
+const redactedQueryValue = "REDACTED"
+
+type TransportOptions struct {
+	// Levels maps a status class (2 for 2xx … 5 for 5xx) to a level, class 0 is used for transport errors.
+	// By default 2xx and 3xx are Info, 4xx Warn, 5xx and errors Error.
+	Levels map[int]logger.Level
+	// MaxBodyBytes dumps request and response bodies up to the limit at Debug, zero disables dumps.
+	MaxBodyBytes int
+	// Redactor is applied to dumped bodies, logger.NewRedactor(nil) is used if nil.
+	Redactor *logger.Redactor
+	// NoPropagation disables setting X-Request-ID and traceparent on outgoing requests.
+	NoPropagation bool
+}
+
+var defaultTransportLevels = map[int]logger.Level{
+	0: logger.LevelError,
+	2: logger.LevelInfo,
+	3: logger.LevelInfo,
+	4: logger.LevelWarn,
+	5: logger.LevelError,
+}
+
+type transport struct {
+	next http.RoundTripper
+	opts TransportOptions
+}
+
+type ctxAttempt struct{}
+
+// ContextWithAttempt marks a request as the n-th attempt of a retry loop, the transport logs it as "attempt".
+func ContextWithAttempt(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, ctxAttempt{}, n)
+}
+
+// NewTransport returns a RoundTripper that logs outbound requests with the logger from the request context.
+// If next is nil, http.DefaultTransport is used.
+func NewTransport(next http.RoundTripper, opts *TransportOptions) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t := &transport{next: next}
+	if opts != nil {
+		t.opts = *opts
+	}
+	if t.opts.Redactor == nil {
+		t.opts.Redactor = logger.NewRedactor(nil)
+	}
+	levels := make(map[int]logger.Level, len(defaultTransportLevels))
+	for k, v := range defaultTransportLevels {
+		levels[k] = v
+	}
+	for k, v := range t.opts.Levels {
+		levels[k] = v
+	}
+	t.opts.Levels = levels
+	return t
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	log := logger.ExtractLogger(ctx)
+
+	if !t.opts.NoPropagation {
+		req = propagate(req)
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", redactURL(req.URL)),
+	}
+	if n, ok := ctx.Value(ctxAttempt{}).(int); ok {
+		attrs = append(attrs, slog.Int("attempt", n))
+	}
+
+	dump := t.opts.MaxBodyBytes > 0 && log.Enabled(ctx, logger.LevelDebug)
+	var reqBody string
+	if dump {
+		reqBody = t.peekRequestBody(req)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	d := time.Since(start)
+
+	if err != nil {
+		attrs = append(attrs, slog.Duration("duration", d), slog.String("error", err.Error()))
+		log.LogAttrs(ctx, t.opts.Levels[0], "http client request failed", attrs...)
+		return resp, err
+	}
+
+	attrs = append(attrs, slog.Int("status", resp.StatusCode), slog.Duration("duration", d))
+	level, ok := t.opts.Levels[resp.StatusCode/100]
+	if !ok {
+		level = logger.LevelInfo
+	}
+
+	done := func(size int64, respBody *capture) {
+		log.LogAttrs(ctx, level, "http client request", append(attrs, slog.Int64("size", size))...)
+		if dump {
+			dumpAttrs := []slog.Attr{slog.String("url", redactURL(req.URL))}
+			if reqBody != "" {
+				dumpAttrs = append(dumpAttrs, slog.String("request_body", reqBody))
+			}
+			if respBody != nil && respBody.buf.Len() > 0 {
+				dumpAttrs = append(dumpAttrs, slog.String("response_body", t.redactBody(respBody)))
+			}
+			log.LogAttrs(ctx, logger.LevelDebug, "http client body", dumpAttrs...)
+		}
+	}
+
+	// 101 bodies are io.ReadWriteClosers used by upgraded connections, they must not be wrapped
+	if resp.Body == nil || resp.Body == http.NoBody || resp.StatusCode == http.StatusSwitchingProtocols {
+		done(0, nil)
+		return resp, nil
+	}
+
+	body := &countingBody{ReadCloser: resp.Body, done: done}
+	if dump {
+		body.capture = &capture{limit: t.opts.MaxBodyBytes}
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// peekRequestBody reads the start of a replayable request body without consuming it.
+func (t *transport) peekRequestBody(req *http.Request) string {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+		return ""
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+
+	c := &capture{limit: t.opts.MaxBodyBytes}
+	b := make([]byte, t.opts.MaxBodyBytes+1)
+	n, _ := io.ReadFull(rc, b)
+	c.Write(b[:n])
+	return t.redactBody(c)
+}
+
+func (t *transport) redactBody(c *capture) string {
+	s := t.opts.Redactor.Redact(c.buf.String())
+	if c.truncated {
+		s += "…"
+	}
+	return s
+}
+
+// propagate returns a copy of req carrying the request ID and a child traceparent of the context.
+func propagate(req *http.Request) *http.Request {
+	ctx := req.Context()
+	id := RequestIDFromContext(ctx)
+	tc, hasTrace := logger.TraceFromContext(ctx)
+
+	setID := id != "" && req.Header.Get(RequestIDHeader) == ""
+	setTrace := hasTrace && req.Header.Get(TraceparentHeader) == ""
+	if !setID && !setTrace {
+		return req
+	}
+
+	req = req.Clone(ctx)
+	if setID {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	if setTrace {
+		req.Header.Set(TraceparentHeader, tc.Child().String())
+	}
+	return req
+}
+
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" && u.User == nil {
+		return u.String()
+	}
+	c := *u
+	if c.RawQuery != "" {
+		q := c.Query()
+		for k := range q {
+			q[k] = []string{redactedQueryValue}
+		}
+		c.RawQuery = q.Encode()
+	}
+	return c.Redacted()
+}
+
+type countingBody struct {
+	io.ReadCloser
+	size    int64
+	capture *capture
+	once    sync.Once
+	done    func(size int64, body *capture)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if b.capture != nil {
+		b.capture.Write(p[:n])
+	}
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+func (b *countingBody) finish() {
+	b.once.Do(func() { b.done(b.size, b.capture) })
+}
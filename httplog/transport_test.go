@@ -0,0 +1,169 @@
+package httplog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FurmanovVitaliy/logger"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// headerServer answers with the request body and records the headers of the last request.
+func headerServer(t *testing.T, status int) (*httptest.Server, *http.Header) {
+	t.Helper()
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(status)
+		io.Copy(w, r.Body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+func tracedContext(t *testing.T, log *logger.Logger) (context.Context, logger.TraceContext) {
+	t.Helper()
+	tc, err := logger.ParseTraceparent(testTraceparent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := logger.ContextWithLogger(context.Background(), log)
+	ctx = logger.ContextWithRequestID(ctx, "req-7")
+	return logger.ContextWithTraceparent(ctx, tc), tc
+}
+
+func TestTransportPropagates(t *testing.T) {
+	srv, got := headerServer(t, http.StatusOK)
+	log, _ := newLog()
+	ctx, tc := tracedContext(t, log)
+	client := &http.Client{Transport: NewTransport(nil, nil)}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got.Get(RequestIDHeader) != "req-7" {
+		t.Fatalf("X-Request-ID = %q", got.Get(RequestIDHeader))
+	}
+	child, err := logger.ParseTraceparent(got.Get(TraceparentHeader))
+	if err != nil || child.TraceID != tc.TraceID || child.SpanID == tc.SpanID {
+		t.Fatalf("traceparent = %q, want a child of %s", got.Get(TraceparentHeader), tc)
+	}
+	if req.Header.Get(RequestIDHeader) != "" {
+		t.Fatal("caller's request modified")
+	}
+
+	// headers set by the caller win
+	req, _ = http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	req.Header.Set(RequestIDHeader, "own")
+	req.Header.Set(TraceparentHeader, testTraceparent)
+	if resp, err = client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got.Get(RequestIDHeader) != "own" || got.Get(TraceparentHeader) != testTraceparent {
+		t.Fatalf("caller headers replaced: %v", *got)
+	}
+
+	client.Transport = NewTransport(nil, &TransportOptions{NoPropagation: true})
+	req, _ = http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	if resp, err = client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got.Get(RequestIDHeader) != "" || got.Get(TraceparentHeader) != "" {
+		t.Fatalf("propagated with NoPropagation: %v", *got)
+	}
+}
+
+func TestTransportThroughMiddleware(t *testing.T) {
+	serverLog, serverBuf := newLog()
+	srv := httptest.NewServer(Middleware(serverLog, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	defer srv.Close()
+
+	clientLog, _ := newLog()
+	ctx, _ := tracedContext(t, clientLog)
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/ping", nil)
+	resp, err := (&http.Client{Transport: NewTransport(nil, nil)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.Header.Get(RequestIDHeader) != "req-7" {
+		t.Fatalf("response X-Request-ID = %q", resp.Header.Get(RequestIDHeader))
+	}
+	access := records(t, serverBuf, "http request")
+	if len(access) != 1 || access[0]["request_id"] != "req-7" {
+		t.Fatalf("server records = %v", access)
+	}
+}
+
+func TestTransportLogs(t *testing.T) {
+	srv, _ := headerServer(t, http.StatusNotFound)
+	log, buf := newLog()
+	ctx, _ := tracedContext(t, log)
+	ctx = ContextWithAttempt(ctx, 2)
+	client := &http.Client{Transport: NewTransport(nil, &TransportOptions{MaxBodyBytes: 8})}
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", srv.URL+"/users?token=s3cret&id=1", strings.NewReader("mail bob@x.io please"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recs := records(t, buf, "http client request"); len(recs) != 0 {
+		t.Fatalf("logged before the body was read: %v", recs)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	recs := records(t, buf, "http client request")
+	if len(recs) != 1 {
+		t.Fatalf("records = %v", recs)
+	}
+	r := recs[0]
+	if r["level"] != "WARN" || r["status"] != 404.0 || r["attempt"] != 2.0 || r["size"] != float64(len(body)) {
+		t.Fatalf("record = %v", r)
+	}
+	if url := r["url"].(string); strings.Contains(url, "s3cret") || !strings.Contains(url, "token=REDACTED") {
+		t.Fatalf("url = %q", url)
+	}
+
+	dumps := records(t, buf, "http client body")
+	if len(dumps) != 1 || dumps[0]["request_body"] != "mail bob…" || dumps[0]["response_body"] != "mail bob…" {
+		t.Fatalf("dumps = %v", dumps)
+	}
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestTransportError(t *testing.T) {
+	log, buf := newLog()
+	ctx, _ := tracedContext(t, log)
+	client := &http.Client{Transport: NewTransport(failingTransport{}, nil)}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://user:pw@example.invalid/", nil)
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("request succeeded")
+	}
+	recs := records(t, buf, "http client request failed")
+	if len(recs) != 1 || recs[0]["level"] != "ERROR" || recs[0]["error"] != "connection refused" {
+		t.Fatalf("records = %v", recs)
+	}
+	if strings.Contains(recs[0]["url"].(string), "pw") {
+		t.Fatalf("password in url %q", recs[0]["url"])
+	}
+}
@@ -12,6 +12,18 @@ const (
 	defaultAsJSON    = true
 	defaultIsDefault = true
 	defaultPrettyOut = false
+	defaultPreset    = PresetNone
+	defaultWithTrace = true
+)
+
+// Preset selects an output layout expected by a log collector, it takes precedence over IsJSON and IsPrettyOut.
+type Preset int
+
+const (
+	PresetNone Preset = iota
+	PresetGCP
+	PresetECS
+	PresetOTel
 )
 
 func NewLogger(opts ...LoggerOption) *Logger {
@@ -21,6 +33,8 @@ func NewLogger(opts ...LoggerOption) *Logger {
 		AsJSON:      defaultAsJSON,
 		IsDefault:   defaultIsDefault,
 		IsPrettyOut: defaultPrettyOut,
+		Preset:      defaultPreset,
+		WithTrace:   defaultWithTrace,
 	}
 
 	for _, opt := range opts {
@@ -35,13 +49,27 @@ func NewLogger(opts ...LoggerOption) *Logger {
 	var h Handler = NewTextHandler(os.Stdout, options)
 
 	if config.IsPrettyOut {
-		h = NewPrettyHandler(os.Stdout, options)
+		h = NewPrettyHandler(os.Stdout, options, config.Pretty...)
 	}
 
 	if config.AsJSON {
 		h = NewJSONHandler(os.Stdout, options)
 	}
 
+	switch config.Preset {
+	case PresetGCP:
+		h = NewGCPHandler(os.Stdout, &GCPOptions{Level: config.Level, AddSource: config.AddSource})
+	case PresetECS:
+		h = NewSchemaHandler(os.Stdout, SchemaECS, options)
+	case PresetOTel:
+		h = NewSchemaHandler(os.Stdout, SchemaOTel, options)
+	}
+
+	if config.WithTrace && config.Preset != PresetGCP {
+		h = NewTraceHandler(h, nil)
+	}
+	h = NewContextHandler(h)
+
 	logger := New(h)
 
 	if config.IsDefault {
@@ -57,6 +85,9 @@ type LoggerOptions struct {
 	AsJSON      bool
 	IsDefault   bool
 	IsPrettyOut bool
+	Preset      Preset
+	WithTrace   bool // true by default, see WithTraceContext
+	Pretty      []PrettyOption
 }
 
 type LoggerOption func(*LoggerOptions)
@@ -101,7 +132,31 @@ func IsPrettyOut(isPretty bool) LoggerOption {
 	}
 }
 
-// WithAttrs returns logger with attributes.
+// WithPrettyOptions logger option sets the options of the pretty output, see IsPrettyOut.
+func WithPrettyOptions(opts ...PrettyOption) LoggerOption {
+	return func(o *LoggerOptions) {
+		o.Pretty = append(o.Pretty, opts...)
+	}
+}
+
+// WithPreset logger option sets the output preset: PresetGCP for Cloud Logging, PresetECS or PresetOTel field names.
+func WithPreset(preset Preset) LoggerOption {
+	return func(o *LoggerOptions) {
+		o.Preset = preset
+	}
+}
+
+// WithTraceContext logger option adds trace_id, span_id and trace_sampled from the record context to
+// every record, see NewTraceHandler. It is enabled by default, pass false to turn it off.
+// PresetGCP always writes the trace with the Cloud Logging keys and ignores this option.
+func WithTraceContext(enabled bool) LoggerOption {
+	return func(o *LoggerOptions) {
+		o.WithTrace = enabled
+	}
+}
+
+// WithAttrs returns logger from context with attributes.
+// To make attributes visible to every logger that gets the context, use ContextWithAttrs.
 func WithAttrs(ctx context.Context, attrs ...Attr) *Logger {
 	logger := ExtractLogger(ctx)
 	for _, attr := range attrs {
@@ -0,0 +1,191 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	maxEMFMetrics    = 100
+	maxEMFDimensions = 30
+	maxEMFValues     = 100
+)
+
+// Unit is a CloudWatch metric unit.
+type Unit string
+
+const (
+	UnitNone           Unit = "None"
+	UnitCount          Unit = "Count"
+	UnitPercent        Unit = "Percent"
+	UnitSeconds        Unit = "Seconds"
+	UnitMilliseconds   Unit = "Milliseconds"
+	UnitMicroseconds   Unit = "Microseconds"
+	UnitBytes          Unit = "Bytes"
+	UnitKilobytes      Unit = "Kilobytes"
+	UnitMegabytes      Unit = "Megabytes"
+	UnitBytesPerSecond Unit = "Bytes/Second"
+	UnitCountPerSecond Unit = "Count/Second"
+)
+
+// DefaultMetricNamespace is the CloudWatch namespace used by Metric.
+var DefaultMetricNamespace = "aws-embedded-metrics"
+
+// metricMu keeps EMF lines of concurrent flushes to a shared writer from interleaving.
+var metricMu sync.Mutex
+
+// Metric writes a single metric in CloudWatch Embedded Metric Format to w, usually os.Stdout
+// or the file the CloudWatch agent reads. Dimensions are written as top-level string fields.
+func Metric(w io.Writer, name string, value float64, unit Unit, dims ...Attr) error {
+	b := NewMetricBatch(w, DefaultMetricNamespace, dims...)
+	b.Add(name, value, unit)
+	return b.Flush(context.Background())
+}
+
+// MetricBatch collects metrics that share a namespace and dimensions and writes them as EMF records,
+// at most 100 metrics per record. Adding the same metric more than once records all values.
+//
+// The records are JSON lines encoded by the batch itself, not log records, so the logger level,
+// handler and schema presets never drop or rename them.
+type MetricBatch struct {
+	w         io.Writer
+	namespace string
+	dims      []Attr
+
+	mu      sync.Mutex
+	metrics []emfMetric
+	index   map[string]int
+}
+
+type emfMetric struct {
+	name   string
+	unit   Unit
+	values []float64
+}
+
+type emfDirective struct {
+	Namespace  string                `json:"Namespace"`
+	Dimensions [][]string            `json:"Dimensions"`
+	Metrics    []emfMetricDefinition `json:"Metrics"`
+}
+
+type emfMetricDefinition struct {
+	Name string `json:"Name"`
+	Unit Unit   `json:"Unit,omitempty"`
+}
+
+// NewMetricBatch returns a batch that writes its records to w.
+func NewMetricBatch(w io.Writer, namespace string, dims ...Attr) *MetricBatch {
+	if len(dims) > maxEMFDimensions {
+		dims = dims[:maxEMFDimensions]
+	}
+	return &MetricBatch{
+		w:         w,
+		namespace: namespace,
+		dims:      dims,
+		index:     make(map[string]int),
+	}
+}
+
+// Add records a value, it is safe for concurrent use.
+func (b *MetricBatch) Add(name string, value float64, unit Unit) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if i, ok := b.index[name]; ok {
+		b.metrics[i].values = append(b.metrics[i].values, value)
+		return
+	}
+	b.index[name] = len(b.metrics)
+	b.metrics = append(b.metrics, emfMetric{name: name, unit: unit, values: []float64{value}})
+}
+
+// Flush writes the collected metrics as one JSON line per 100 metrics and resets the batch.
+// Values that are NaN or infinite are dropped, EMF cannot represent them. Nothing is written
+// if ctx is already done.
+func (b *MetricBatch) Flush(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	metrics := b.metrics
+	b.metrics = nil
+	b.index = make(map[string]int)
+	b.mu.Unlock()
+
+	var buf []byte
+	for len(metrics) > 0 {
+		n := min(len(metrics), maxEMFMetrics)
+		fields := b.record(metrics[:n])
+		metrics = metrics[n:]
+		if fields == nil {
+			continue
+		}
+		line, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+
+	metricMu.Lock()
+	defer metricMu.Unlock()
+	_, err := b.w.Write(buf)
+	return err
+}
+
+// record returns the fields of one EMF record, metrics without a finite value are left out
+// and nil is returned if none is left.
+func (b *MetricBatch) record(metrics []emfMetric) map[string]any {
+	fields := make(map[string]any, len(b.dims)+len(metrics)+1)
+	dimKeys := make([]string, 0, len(b.dims))
+	defs := make([]emfMetricDefinition, 0, len(metrics))
+
+	for _, d := range b.dims {
+		dimKeys = append(dimKeys, d.Key)
+		fields[d.Key] = d.Value.Resolve().String()
+	}
+
+	for _, m := range metrics {
+		values := make([]float64, 0, min(len(m.values), maxEMFValues))
+		for _, v := range m.values[max(len(m.values)-maxEMFValues, 0):] {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				values = append(values, v)
+			}
+		}
+		switch len(values) {
+		case 0:
+			continue
+		case 1:
+			fields[m.name] = values[0]
+		default:
+			fields[m.name] = values
+		}
+		defs = append(defs, emfMetricDefinition{Name: m.name, Unit: m.unit})
+	}
+	if len(defs) == 0 {
+		return nil
+	}
+
+	dimensions := [][]string{}
+	if len(dimKeys) > 0 {
+		dimensions = append(dimensions, dimKeys)
+	}
+	fields["_aws"] = map[string]any{
+		"Timestamp": time.Now().UnixMilli(),
+		"CloudWatchMetrics": []emfDirective{{
+			Namespace:  b.namespace,
+			Dimensions: dimensions,
+			Metrics:    defs,
+		}},
+	}
+	return fields
+}
@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+type emfRecord struct {
+	AWS struct {
+		Timestamp         int64          `json:"Timestamp"`
+		CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+	} `json:"_aws"`
+	Fields map[string]any `json:"-"`
+}
+
+func decodeEMF(t *testing.T, buf *bytes.Buffer) []emfRecord {
+	t.Helper()
+	var out []emfRecord
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var r emfRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid EMF line %q: %v", line, err)
+		}
+		if err := json.Unmarshal([]byte(line), &r.Fields); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, r)
+	}
+	buf.Reset()
+	return out
+}
+
+func TestMetricEnvelope(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Metric(&buf, "latency", 12.5, UnitMilliseconds, slog.String("service", "api"), slog.Int("shard", 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	recs := decodeEMF(t, &buf)
+	if len(recs) != 1 {
+		t.Fatalf("records = %+v", recs)
+	}
+	r := recs[0]
+	if d := time.Since(time.UnixMilli(r.AWS.Timestamp)); d < 0 || d > time.Minute {
+		t.Fatalf("timestamp = %d", r.AWS.Timestamp)
+	}
+	if len(r.AWS.CloudWatchMetrics) != 1 {
+		t.Fatalf("_aws = %+v", r.AWS)
+	}
+	dir := r.AWS.CloudWatchMetrics[0]
+	if dir.Namespace != DefaultMetricNamespace {
+		t.Fatalf("namespace = %q", dir.Namespace)
+	}
+	if len(dir.Dimensions) != 1 || strings.Join(dir.Dimensions[0], ",") != "service,shard" {
+		t.Fatalf("dimensions = %v", dir.Dimensions)
+	}
+	if len(dir.Metrics) != 1 || dir.Metrics[0] != (emfMetricDefinition{Name: "latency", Unit: UnitMilliseconds}) {
+		t.Fatalf("metrics = %+v", dir.Metrics)
+	}
+	if r.Fields["latency"] != 12.5 || r.Fields["service"] != "api" || r.Fields["shard"] != "3" {
+		t.Fatalf("fields = %v", r.Fields)
+	}
+}
+
+func TestMetricBatch(t *testing.T) {
+	var buf bytes.Buffer
+	b := NewMetricBatch(&buf, "app")
+
+	b.Add("hits", 1, UnitCount)
+	b.Add("hits", 2, UnitCount)
+	b.Add("ratio", math.NaN(), UnitPercent)
+	for i := range maxEMFMetrics {
+		b.Add(fmt.Sprintf("m%d", i), float64(i), UnitNone)
+	}
+	if err := b.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	recs := decodeEMF(t, &buf)
+	if len(recs) != 2 {
+		t.Fatalf("%d records, want the metrics split in two", len(recs))
+	}
+	first := recs[0]
+	if len(first.AWS.CloudWatchMetrics[0].Dimensions) != 0 {
+		t.Fatalf("dimensions = %v", first.AWS.CloudWatchMetrics[0].Dimensions)
+	}
+	if hits, _ := first.Fields["hits"].([]any); len(hits) != 2 || hits[0] != 1.0 || hits[1] != 2.0 {
+		t.Fatalf("hits = %v", first.Fields["hits"])
+	}
+	if _, ok := first.Fields["ratio"]; ok {
+		t.Fatal("NaN metric written")
+	}
+	n := len(first.AWS.CloudWatchMetrics[0].Metrics) + len(recs[1].AWS.CloudWatchMetrics[0].Metrics)
+	if n != maxEMFMetrics+1 {
+		t.Fatalf("%d metric definitions, want %d", n, maxEMFMetrics+1)
+	}
+
+	if err := b.Flush(context.Background()); err != nil || buf.Len() != 0 {
+		t.Fatalf("empty flush wrote %q, err %v", buf.String(), err)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestMetricWriteError(t *testing.T) {
+	if err := Metric(failingWriter{}, "hits", 1, UnitCount); err == nil || err.Error() != "disk full" {
+		t.Fatalf("err = %v", err)
+	}
+
+	var buf bytes.Buffer
+	b := NewMetricBatch(&buf, "app")
+	b.Add("hits", 1, UnitCount)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.Flush(ctx); !errors.Is(err, context.Canceled) || buf.Len() != 0 {
+		t.Fatalf("flush with a done context: %v, wrote %q", err, buf.String())
+	}
+	if err := b.Flush(context.Background()); err != nil || len(decodeEMF(t, &buf)) != 1 {
+		t.Fatalf("metrics lost after a canceled flush: %v", err)
+	}
+}
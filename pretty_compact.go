@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	compactTimeLayout = "15:04:05.000"
+	compactLevelWidth = 5
+)
+
+// PrettyLayout selects how the pretty handler renders records.
+type PrettyLayout int32
+
+const (
+	// LayoutBoxed renders every record as a box with the attrs as a tree.
+	LayoutBoxed PrettyLayout = iota
+	// LayoutCompact renders one line per record with group-qualified key=value pairs.
+	LayoutCompact
+)
+
+// PrettyLayouter provides a PrettyLayout, it is implemented by PrettyLayout and *PrettyLayoutVar.
+type PrettyLayouter interface {
+	PrettyLayout() PrettyLayout
+}
+
+func (l PrettyLayout) PrettyLayout() PrettyLayout {
+	return l
+}
+
+func (l PrettyLayout) String() string {
+	switch l {
+	case LayoutBoxed:
+		return "boxed"
+	case LayoutCompact:
+		return "compact"
+	default:
+		return "PrettyLayout(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+// PrettyLayoutVar is a PrettyLayout that can be changed while logging, like slog.LevelVar.
+// Every logger derived from a handler created with the variable follows it.
+type PrettyLayoutVar struct {
+	v atomic.Int32
+}
+
+func (v *PrettyLayoutVar) PrettyLayout() PrettyLayout {
+	return PrettyLayout(v.v.Load())
+}
+
+func (v *PrettyLayoutVar) Set(l PrettyLayout) {
+	v.v.Store(int32(l))
+}
+
+// Toggle switches between LayoutBoxed and LayoutCompact and returns the new layout.
+func (v *PrettyLayoutVar) Toggle() PrettyLayout {
+	for {
+		old := v.v.Load()
+		l := LayoutCompact
+		if PrettyLayout(old) == LayoutCompact {
+			l = LayoutBoxed
+		}
+		if v.v.CompareAndSwap(old, int32(l)) {
+			return l
+		}
+	}
+}
+
+func (v *PrettyLayoutVar) String() string {
+	return "PrettyLayoutVar(" + v.PrettyLayout().String() + ")"
+}
+
+/*--------------------------------RENDERER---------------------------------------------------*/
+
+// compactWidth returns the line width, zero if the output is not a terminal and lines are not limited.
+func (h *prettyHandler) compactWidth() int {
+	if width, ok := h.width.get(); ok {
+		return max(width, minPrettyWidth)
+	}
+	return 0
+}
+
+// compactLine writes time, level badge, message and key=value pairs on one line with the source right-aligned.
+// Lines longer than the terminal are truncated with … or wrapped below the message if CompactWrap is set.
+func (h *prettyHandler) compactLine(p *prettyRenderer, r slog.Record, attrs []slog.Attr) {
+	if t, ok := h.time(r); ok {
+		p.setColor(p.theme.Time)
+		n := len(p.buf)
+		p.buf = h.appendStamp(p.buf, t, compactTimeLayout)
+		p.col += textWidth(string(p.buf[n:]))
+		p.resetColor(p.theme.Time)
+		p.ascii(" ")
+	}
+	start := p.col
+	h.level(p, r, false)
+	p.fill(' ', start+compactLevelWidth)
+	p.ascii(" ")
+	p.indent = p.col
+
+	src, hasSource := h.source(r)
+	srcW := 0
+	if hasSource {
+		if !h.srcFmt.Relative {
+			src.File = filepath.Base(src.File)
+		}
+		srcW = src.width()
+	}
+
+	msg := h.message(r)
+	if strings.ContainsFunc(msg, unicode.IsControl) {
+		msg = strconv.Quote(msg)
+	}
+
+	// the source is moved below the line if the message would be clipped to less than minValueWidth columns
+	p.limit = p.width
+	below := false
+	if p.width > 0 && hasSource {
+		below = p.width-srcW-1 < p.indent+min(textWidth(msg), minValueWidth)
+		if below {
+			src = src.fit(p.width)
+			srcW = src.width()
+		} else if !h.wrap {
+			p.limit = p.width - srcW - 1
+		}
+	}
+
+	p.clip = p.limit > 0 && p.col+textWidth(msg) > p.limit
+	p.put(msg, ColorNone)
+
+	for _, a := range h.rootAttrs {
+		p.compactAttr(nil, a)
+	}
+	groups := 0
+	for _, goa := range h.goas {
+		if goa.group != "" {
+			groups++
+			continue
+		}
+		for _, a := range goa.attrs {
+			p.compactAttr(h.groups[:groups], a)
+		}
+	}
+	for _, a := range attrs {
+		p.compactAttr(h.groups, a)
+	}
+
+	if hasSource {
+		switch {
+		case p.width == 0:
+			p.ascii("  ")
+		case !below && p.col+1+srcW <= p.width:
+			p.fill(' ', p.width-srcW)
+		default:
+			p.buf = append(p.buf, '\n')
+			p.col = 0
+			p.fill(' ', p.width-srcW)
+		}
+		p.source(src, p.theme.Source)
+	}
+	p.buf = append(p.buf, '\n')
+	p.col = 0
+}
+
+// compactAttr writes " key=value" for a or for every attr of a group, keys are qualified with the groups in prefix.
+func (p *prettyRenderer) compactAttr(prefix []string, a slog.Attr) {
+	if p.cut {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		prefix = append(prefix[:len(prefix):len(prefix)], a.Key)
+		for _, ga := range a.Value.Group() {
+			p.compactAttr(prefix, ga)
+		}
+		return
+	}
+
+	value := a.Value.String()
+	if needsQuoting(value) {
+		value = strconv.Quote(value)
+	}
+	w := 1 + textWidth(a.Key) + 1 + textWidth(value)
+	for _, g := range prefix {
+		w += textWidth(g) + 1
+	}
+
+	p.clip = false
+	if p.limit > 0 && p.col+w > p.limit {
+		if p.wrap && p.col > p.indent {
+			p.buf = append(p.buf, '\n')
+			p.col = 0
+			p.fill(' ', p.indent-1)
+		} else {
+			p.clip = true
+		}
+	}
+
+	c := p.theme.keyColor(len(prefix))
+	p.put(" ", ColorNone)
+	for _, g := range prefix {
+		p.put(g, c)
+		p.put(".", c)
+	}
+	p.put(a.Key, c)
+	p.put("=", ColorNone)
+	p.put(value, ColorNone)
+}
+
+// put writes s in color c, if the current piece is clipped s is cut at the line limit and marked with ….
+func (p *prettyRenderer) put(s string, c Color) {
+	if p.cut {
+		return
+	}
+	if !p.clip {
+		p.colored(c, s)
+		return
+	}
+	room := p.limit - p.col - 1
+	if textWidth(s) <= room {
+		p.colored(c, s)
+		return
+	}
+	p.colored(c, runewidth.Truncate(s, max(room, 0), ""))
+	p.ascii("…")
+	p.cut = true
+}
+
+// needsQuoting reports whether a compact value must be quoted to stay one unambiguous token.
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r == ' ' || r == '=' || r == '"' || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
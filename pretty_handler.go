@@ -1,472 +1,991 @@
 package logger
 
 import (
-	"bytes"
 	"context"
-	"fmt"
 	"io"
 	"log/slog"
-	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
-	"github.com/fatih/color"
 	"github.com/mattn/go-runewidth"
-	"golang.org/x/term"
 )
 
-var keyColors = []func(string) string{
-	func(s string) string { return color.HiGreenString(s) },
-	func(s string) string { return color.HiYellowString(s) },
-	func(s string) string { return color.HiCyanString(s) },
-	func(s string) string { return color.HiMagentaString(s) },
-	func(s string) string { return color.HiRedString(s) },
+const (
+	defaultPrettyWidth = 100
+	minPrettyWidth     = 40
+	minValueWidth      = 8
+	tabWidth           = 4
+	maxPooledBufSize   = 64 << 10
+)
+
+var levelEmoji = map[slog.Level]string{
+	slog.LevelDebug: "🔧",
+	slog.LevelInfo:  "🌐",
+	slog.LevelWarn:  "⚠️ ",
+	slog.LevelError: "🛑",
 }
 
-type attrWithInfo struct {
-	attr       slog.Attr
-	extraLine  string
-	firstChild bool
-	neasted    bool
-	lastChild  bool
+var prettyBufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 2048)
+		return &b
+	},
 }
 
 type groupOrAttrs struct {
 	group string
 	attrs []slog.Attr
 }
+
 type prettyHandler struct {
-	jsonH slog.Handler
-	opts  HandlerOptions
-	goas  []groupOrAttrs
+	opts HandlerOptions
+	// rootAttrs are the attrs added before the first WithGroup call, pre is their rendering for preWidth.
+	rootAttrs []slog.Attr
+	pre       []byte
+	preWidth  int
+	// goas starts with the first WithGroup call, attrs are resolved and replaced when they are added.
+	goas   []groupOrAttrs
+	groups []string
+	colors bool
+	theme  *PrettyTheme
+	glyphs *glyphs
+	layout PrettyLayouter
+	wrap   bool
+
+	// mu is shared by all handlers derived from one NewPrettyHandler call and only guards writes to out.
 	mu    *sync.Mutex
 	out   io.Writer
+	width *termWidth
+	// values holds the expansion limits, it is copied for every attr since it tracks the cycle path.
+	values   valueTree
+	maxLines int
+	srcFmt   SourceFormat
+	link     string
+	timeFmt  PrettyTime
+	// last is the time of the previous record in unix nanoseconds, shared like mu.
+	last *atomic.Int64
+}
+
+// prettyRenderer holds the layout state of a single Handle call, so handlers are safe for concurrent use.
+type prettyRenderer struct {
+	buf    []byte
+	width  int
+	col    int
+	colors bool
+	theme  *PrettyTheme
+	g      *glyphs
+	// rails[d] reports whether the node open at depth d has following siblings.
+	rails []bool
+	// narrow is set for trees too deep for the width, they are drawn with one column rails.
+	narrow bool
+
+	// maxLines caps the lines of multi-line values, zero shows all.
+	maxLines int
+
+	// compact layout: attrs past limit are wrapped to indent or clipped, cut is set once clipped.
+	indent, limit   int
+	wrap, clip, cut bool
+}
+
+type PrettyOptions struct {
+	Theme PrettyTheme
+	// Layout is LayoutBoxed by default, pass a *PrettyLayoutVar to switch it while logging.
+	Layout PrettyLayouter
+	// CompactWrap wraps compact lines at the terminal width instead of truncating them.
+	CompactWrap bool
+	// Width fixes the line width, by default COLUMNS or the terminal size of the output is used,
+	// other writers get defaultPrettyWidth.
+	Width int
+	// MaxDepth and MaxElements limit how deep and how wide maps, slices and structs are expanded.
+	MaxDepth    int
+	MaxElements int
+	// ExpandJSON renders string values holding a JSON object or array as trees.
+	ExpandJSON bool
+	// MaxLines caps the lines shown of a multi-line value, zero shows all of them.
+	MaxLines int
+	// Source sets the path and function shown with the source, the absolute path is shown by default.
+	Source SourceFormat
+	// SourceLink turns the source into an OSC 8 hyperlink to the template, see SourceLinkFile.
+	// Links are written only with colors.
+	SourceLink string
+	// Time sets the layout, location, elapsed and delta display of the timestamp.
+	Time PrettyTime
+}
+
+// PrettyOption configures NewPrettyHandler and WithPrettyOptions, the constructors start with PrettyWith
+// so they are not mistaken for LoggerOptions.
+type PrettyOption func(*PrettyOptions)
+
+// PrettyWithLayout pretty option sets the layout, LayoutCompact writes one line per record.
+func PrettyWithLayout(layout PrettyLayouter) PrettyOption {
+	return func(o *PrettyOptions) {
+		o.Layout = layout
+	}
+}
 
-	jsonBuf             bytes.Buffer
-	tWidth              int
-	lWidth              int
-	firstLine           bool
-	lastLine            bool
-	disableActiveIndent bool
-	activeIndent        map[int]bool
+// PrettyWithCompactWrap pretty option wraps compact lines that do not fit the terminal instead of truncating them.
+func PrettyWithCompactWrap(wrap bool) PrettyOption {
+	return func(o *PrettyOptions) {
+		o.CompactWrap = wrap
+	}
+}
+
+// PrettyWithWidth pretty option fixes the line width instead of detecting it, widths below 40 columns are raised to 40.
+func PrettyWithWidth(width int) PrettyOption {
+	return func(o *PrettyOptions) {
+		o.Width = width
+	}
 }
 
-func NewPrettyHandler(out io.Writer, opts *HandlerOptions) *prettyHandler {
+// PrettyWithValueLimits pretty option sets how many levels and elements of maps, slices and structs are
+// expanded, the rest is summarized. Values not above zero keep the defaults of 5 levels and 20 elements.
+func PrettyWithValueLimits(depth, elements int) PrettyOption {
+	return func(o *PrettyOptions) {
+		o.MaxDepth = depth
+		o.MaxElements = elements
+	}
+}
+
+// PrettyWithExpandJSON pretty option renders string values holding a JSON object or array as trees.
+func PrettyWithExpandJSON(expand bool) PrettyOption {
+	return func(o *PrettyOptions) {
+		o.ExpandJSON = expand
+	}
+}
+
+// PrettyWithMaxLines pretty option caps the lines shown of multi-line values such as stack traces,
+// the number of hidden lines is noted below them.
+func PrettyWithMaxLines(lines int) PrettyOption {
+	return func(o *PrettyOptions) {
+		o.MaxLines = lines
+	}
+}
+
+// PrettyWithSourceFormat pretty option shortens the source path and adds the function name.
+func PrettyWithSourceFormat(format SourceFormat) PrettyOption {
+	return func(o *PrettyOptions) {
+		o.Source = format
+	}
+}
+
+// PrettyWithSourceLink pretty option makes the source a hyperlink that terminals supporting OSC 8 open,
+// template is SourceLinkFile, SourceLinkVSCode or another URL with {path} and {line} placeholders.
+// {path} is percent-encoded.
+func PrettyWithSourceLink(template string) PrettyOption {
+	return func(o *PrettyOptions) {
+		o.SourceLink = template
+	}
+}
+
+// PrettyWithTime pretty option sets how the timestamp is shown, the delta to the previous record helps
+// to spot slow steps.
+func PrettyWithTime(t PrettyTime) PrettyOption {
+	return func(o *PrettyOptions) {
+		o.Time = t
+	}
+}
+
+// PrettyWithTheme pretty option sets the colors, glyphs and emoji, DarkTheme is used by default.
+// Colors are written only to terminals, NO_COLOR and FORCE_COLOR override the detection.
+func PrettyWithTheme(theme PrettyTheme) PrettyOption {
+	return func(o *PrettyOptions) {
+		o.Theme = theme
+	}
+}
+
+func NewPrettyHandler(out io.Writer, opts *HandlerOptions, prettyOpts ...PrettyOption) *prettyHandler {
 	if opts == nil {
 		opts = &HandlerOptions{}
 	}
+	config := &PrettyOptions{
+		Theme:  DarkTheme,
+		Layout: LayoutBoxed,
+	}
+	for _, opt := range prettyOpts {
+		opt(config)
+	}
+	if config.MaxDepth <= 0 {
+		config.MaxDepth = defaultMaxDepth
+	}
+	if config.MaxElements <= 0 {
+		config.MaxElements = defaultMaxElements
+	}
+
+	g, ok := glyphSets[config.Theme.Glyphs]
+	if !ok {
+		g = glyphSets[GlyphsRounded]
+	}
 
 	h := &prettyHandler{
-		out:          out,
-		mu:           &sync.Mutex{},
-		activeIndent: make(map[int]bool),
-		opts:         *opts,
+		out:      out,
+		mu:       &sync.Mutex{},
+		opts:     *opts,
+		colors:   colorsEnabled(out),
+		theme:    &config.Theme,
+		glyphs:   g,
+		layout:   config.Layout,
+		wrap:     config.CompactWrap,
+		width:    outputWidth(out, config.Width),
+		values:   valueTree{maxDepth: config.MaxDepth, maxElems: config.MaxElements, json: config.ExpandJSON},
+		maxLines: config.MaxLines,
+		srcFmt:   config.Source,
+		link:     config.SourceLink,
+		timeFmt:  config.Time,
+		last:     &atomic.Int64{},
 	}
 
 	if h.opts.Level == nil {
 		h.opts.Level = slog.LevelDebug
 	}
 
-	h.jsonH = slog.NewJSONHandler(&h.jsonBuf, &slog.HandlerOptions{
-		Level: h.opts.Level,
-	})
-
 	return h
 }
 
 /*--------------------------------HANDLER---------------------------------------------------*/
 func (h *prettyHandler) Handle(ctx context.Context, r slog.Record) error {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	bp := prettyBufPool.Get().(*[]byte)
+	defer func() {
+		if cap(*bp) <= maxPooledBufSize {
+			prettyBufPool.Put(bp)
+		}
+	}()
 
-	buf := make([]byte, 0, 1024)
-	var path, timestamp string
-	indentLevel := 0
+	var attrs []slog.Attr
+	if r.NumAttrs() > 0 {
+		attrs = make([]slog.Attr, 0, r.NumAttrs())
+		r.Attrs(func(a slog.Attr) bool {
+			attrs = h.appendResolved(attrs, h.groups, a)
+			return true
+		})
+	}
 
-	// terminal width logic
-	width, _, err := term.GetSize(int(os.Stdout.Fd()))
-	if err != nil {
-		width = 99
+	var p prettyRenderer
+	if h.layout.PrettyLayout() == LayoutCompact {
+		p = h.renderer((*bp)[:0], h.compactWidth())
+		h.compactLine(&p, r, attrs)
+	} else {
+		p = h.renderer((*bp)[:0], h.lineWidth())
+		h.box(&p, r, attrs)
 	}
+	*bp = p.buf
 
-	h.lWidth = width + 5
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.out.Write(p.buf)
+	return err
+}
 
-	if !r.Time.IsZero() {
-		timestamp = fmt.Sprintf("[%s %s]", "🕙", r.Time.Format(time.Stamp))
-	}
+func (h *prettyHandler) box(p *prettyRenderer, r slog.Record, attrs []slog.Attr) {
+	h.header(p, r)
 
-	h.firstLine = true
-	msg := slog.String(colorizeLevel(r.Level), r.Message)
-	if h.lWidth < len(msg.String())+10+len(timestamp) {
-		buf = h.appendAttr(buf, attrWithInfo{msg, "", false, false, false}, indentLevel)
+	if h.preWidth == p.width {
+		p.buf = append(p.buf, h.pre...)
 	} else {
-		buf = h.appendAttr(buf, attrWithInfo{msg, timestamp, false, false, false}, indentLevel)
+		for _, a := range h.rootAttrs {
+			p.attr(a, 0, false)
+		}
 	}
-	h.firstLine = false
 
-	for _, goa := range h.goas {
-		if len(goa.attrs) > 0 {
-			for _, a := range goa.attrs {
-				buf = h.appendAttr(buf, attrWithInfo{a, "", false, false, false}, indentLevel)
-			}
-		}
-		if goa.group != "" {
-			group := slog.String("GROUP", goa.group)
-			buf = h.appendAttr(buf, attrWithInfo{group, "", false, false, false}, indentLevel)
+	if len(h.goas) > 0 {
+		p.scope(h.goas, 0, attrs)
+	} else {
+		for i, a := range attrs {
+			p.attr(a, 0, i == len(attrs)-1)
 		}
 	}
 
-	r.Attrs(func(a slog.Attr) bool {
-		buf = h.appendAttr(buf, attrWithInfo{a, "", false, false, false}, indentLevel)
-		return true
-	})
-
-	if h.opts.AddSource && r.PC != 0 {
-		fs := runtime.CallersFrames([]uintptr{r.PC})
-		f, _ := fs.Next()
-		path = fmt.Sprintf("%s:%d", f.File, f.Line)
-		h.lastLine = true
-		source := slog.String("source", path)
-		buf = h.appendAttr(buf, attrWithInfo{source, "", false, false, false}, indentLevel)
-		h.lastLine = false
+	if src, ok := h.source(r); ok {
+		p.footer(src)
 	}
 	/*
-		buf = fmt.Append(buf, "\n")
-		if h.opts.Level.Level() < slog.LevelInfo && h.tWidth > 158 {
-			addSysInfo(&buf)
+		if h.opts.Level.Level() < slog.LevelInfo && p.width > 158 {
+			addSysInfo(&p.buf, p.width)
 		}
 	*/
-	_, err = h.out.Write(buf)
-	return err
 }
-func (h *prettyHandler) appendAttr(buf []byte, a attrWithInfo, indentLevel int) []byte {
-	a.attr.Value = a.attr.Value.Resolve()
-	if a.attr.Equal(slog.Attr{}) {
-		return buf
-	}
 
-	switch a.attr.Value.Kind() {
-	case slog.KindString:
-		groupLine := false
-		if a.attr.Key == "GROUP" {
-			groupLine = true
+func (h *prettyHandler) header(p *prettyRenderer, r slog.Record) {
+	var stamp []byte
+	if t, ok := h.time(r); ok {
+		var b [64]byte
+		stamp = append(b[:0], '[')
+		if !h.theme.NoEmoji {
+			stamp = append(stamp, "🕙 "...)
 		}
+		stamp = append(h.appendStamp(stamp, t, time.Stamp), ']')
+	}
 
-		key := colorizeKey(indentLevel, a.attr.Key)
-		str := fmt.Sprintf("%s: %q", key, a.attr.Value.String())
-
-		switch true {
-		case h.firstLine:
-			str = fmt.Sprintf("[%s: %q]", a.attr.Key, a.attr.Value.String())
-			str = h.alignValues(str, indentLevel, '-', '─', false, a, false, false)
-			str = appendInRight(str, a.extraLine)
-			buf = fmt.Append(buf, "╭")
-			buf = fmt.Append(buf, str)
-			buf = fmt.Append(buf, "╮")
-		case groupLine:
-			str = fmt.Sprintf("[%s %s: %q]", "📂", color.HiWhiteString(a.attr.Key), a.attr.Value.String())
-			str = h.alignValues(str, indentLevel, ' ', '─', false, a, false, false)
-			str = appendInRight(str, a.extraLine)
-			buf = fmt.Append(buf, "├")
-			buf = fmt.Append(buf, str)
-			buf = fmt.Append(buf, "│")
-		case h.lastLine:
-
-			str = fmt.Sprintf("[%s: %q] ", a.attr.Key, a.attr.Value.String())
-			str = h.alignValues(str, indentLevel, '╴', '─', true, a, false, false)
-			buf = fmt.Append(buf, "╰")
-			buf = fmt.Append(buf, str)
-			buf = fmt.Append(buf, "╯")
-		case a.firstChild:
-			if h.isLongValue(str, indentLevel) {
-				h.wrapLongValue(&buf, a, key, a.attr.Value.String(), indentLevel)
-			} else {
-				str = fmt.Sprintf("%s: %q", key, a.attr.Value.String())
-				str = h.alignValues(str, indentLevel, ' ', '─', false, a, false, false)
-				str = appendInRight(str, a.extraLine)
-				buf = fmt.Append(buf, "│")
-				buf = fmt.Append(buf, str)
-				buf = fmt.Append(buf, "│")
-			}
-		case a.neasted:
+	p.ascii(p.g.topLeft)
+	p.ascii(p.g.lead)
+	p.ascii("[")
+	h.level(p, r, true)
+	// a message that does not fit the header or has several lines is written below it
+	msg := h.message(r)
+	long := p.col+quotedWidth(msg)+4 > p.width || strings.Contains(msg, "\n")
+	if !long {
+		p.ascii(": ")
+		p.quoted(msg)
+	}
+	p.ascii("]")
 
-			if h.isLongValue(str, indentLevel) {
-				h.wrapLongValue(&buf, a, key, a.attr.Value.String(), indentLevel)
-			} else {
-				str = h.alignValues(str, indentLevel, ' ', '─', false, a, false, false)
-				str = appendInRight(str, a.extraLine)
-				buf = fmt.Append(buf, "│")
-				buf = fmt.Append(buf, str)
-				buf = fmt.Append(buf, "│")
-			}
-		case a.lastChild:
-			if h.isLongValue(str, indentLevel) {
-				h.wrapLongValue(&buf, a, key, a.attr.Value.String(), indentLevel)
-			} else {
-				str = h.alignValues(str, indentLevel, ' ', '─', false, a, false, false)
-				str = appendInRight(str, a.extraLine)
-				buf = fmt.Append(buf, "│")
-				buf = fmt.Append(buf, str)
-				buf = fmt.Append(buf, "│")
-			}
-		default:
-			if h.isLongValue(str, indentLevel) {
-				h.wrapLongValue(&buf, a, key, a.attr.Value.String(), indentLevel)
-			} else {
+	// a stamp that does not fit the header is right-aligned on the line below it
+	sw := textWidth(string(stamp))
+	below := len(stamp) > 0 && p.col+sw+6 > p.width
+	if len(stamp) > 0 && !below {
+		p.fill(p.g.rule, p.width-5-sw)
+		p.colored(p.theme.Time, string(stamp))
+	}
+	if p.g.topRight != "" {
+		p.fill(p.g.rule, p.width)
+	}
+	p.endLine(p.g.topRight)
 
-				str = fmt.Sprintf("[%s: %q] ", key, a.attr.Value.String())
-				str = h.alignValues(str, indentLevel, ' ', '─', false, a, false, false)
-				str = appendInRight(str, a.extraLine)
-				buf = fmt.Append(buf, "├")
-				buf = fmt.Append(buf, str)
-				buf = fmt.Append(buf, "│")
+	if below {
+		// the brackets and emoji are dropped if the stamp is wider than the box
+		if sw+2 > p.width {
+			stamp = stamp[1 : len(stamp)-1]
+			if !p.theme.NoEmoji {
+				stamp = stamp[len("🕙 "):]
 			}
+			sw = textWidth(string(stamp))
 		}
-		buf = fmt.Append(buf, "\n")
-	case slog.KindGroup:
-		attrs := a.attr.Value.Group()
-		if len(attrs) == 0 {
-			return buf
-		}
-		str := fmt.Sprintf("%s%s:", "📦 ", colorizeKey(indentLevel, a.attr.Key))
-		str = h.alignValues(str, indentLevel, ' ', '─', false, a, true, false)
-		str = appendInRight(str, a.extraLine)
-		buf = fmt.Append(buf, "│", str, "│\n")
-		indentLevel++
-		for i, ga := range attrs {
-			isFirst := i == 0
-			isLast := i == len(attrs)-1
-			buf = h.appendAttr(buf, attrWithInfo{ga, "", isFirst, true, isLast}, indentLevel)
-		}
-	default:
-		buf = h.appendAttr(buf, attrWithInfo{slog.String(a.attr.Key, a.attr.Value.String()), "", a.firstChild, a.neasted, a.lastChild}, indentLevel)
+		p.ascii(p.g.left)
+		p.fill(' ', p.width-sw-1)
+		p.colored(p.theme.Time, string(stamp))
+		p.endLine(p.g.right)
 	}
-	return buf
-}
 
-func (h *prettyHandler) isLongValue(str string, identLevel int) bool {
-	return len(str) > h.lWidth-identLevel*6
+	if long {
+		p.ascii(p.g.tee)
+		p.ascii(p.g.bullet)
+		p.value(slog.MessageKey, len(slog.MessageKey), msg, 0, false)
+	}
 }
 
-/*--------------------------------UTILS------------------------------------------------------*/
+/*--------------------------------BUILT-IN ATTRS---------------------------------------------*/
+
+// time returns the record time after ReplaceAttr, ok is false for zero or removed times.
+func (h *prettyHandler) time(r slog.Record) (slog.Value, bool) {
+	if r.Time.IsZero() {
+		return slog.Value{}, false
+	}
+	a := h.replaceBuiltin(slog.Time(slog.TimeKey, r.Time))
+	return a.Value, a.Key != ""
+}
 
-func (h *prettyHandler) alignValues(text string, identLevel int, spacer, ident rune, center bool, a attrWithInfo, key, wraped bool) string {
-	prefix := strings.Repeat(" ", identLevel*6)
+// level writes the level badge, emoji reports whether the theme emoji is added.
+func (h *prettyHandler) level(p *prettyRenderer, r slog.Record, emoji bool) {
+	a := h.replaceBuiltin(slog.Any(slog.LevelKey, r.Level))
+	if a.Key == "" {
+		return
+	}
+	if l, ok := a.Value.Any().(slog.Level); !ok || l != r.Level {
+		p.text(a.Value.String())
+		return
+	}
 
-	if identLevel == 1 && a.lastChild {
-		h.disableActiveIndent = true
+	c := p.theme.levelColor(r.Level)
+	p.setColor(c)
+	if emoji && !p.theme.NoEmoji {
+		p.text(levelEmoji[standardLevel(r.Level)])
+		p.ascii(" ")
 	}
-	if identLevel == 1 && a.firstChild {
-		h.disableActiveIndent = false
+	p.ascii(r.Level.String())
+	p.resetColor(c)
+}
+
+func (h *prettyHandler) message(r slog.Record) string {
+	if h.opts.ReplaceAttr == nil {
+		return r.Message
 	}
-	if identLevel > 1 {
-		prefix = h.addIndentSpaces(identLevel)
+	a := h.replaceBuiltin(slog.String(slog.MessageKey, r.Message))
+	if a.Key == "" {
+		return ""
 	}
+	return a.Value.String()
+}
 
-	if !key && a.lastChild && a.neasted {
-		h.activeIndent[identLevel] = false
+// source returns the source location of r formatted and passed to ReplaceAttr, Line is zero if
+// ReplaceAttr replaced the location with another value.
+func (h *prettyHandler) source(r slog.Record) (src prettySource, ok bool) {
+	if !h.opts.AddSource || r.PC == 0 {
+		return prettySource{}, false
 	}
-	if key && !a.lastChild && a.neasted {
-		h.activeIndent[identLevel] = true
+	fs := runtime.CallersFrames([]uintptr{r.PC})
+	f, _ := fs.Next()
+	if h.link != "" && h.colors {
+		src.link = sourceLink(h.link, f.File, strconv.Itoa(f.Line))
 	}
+	s := h.srcFmt.format(slog.Source{Function: f.Function, File: f.File, Line: f.Line})
+	a := h.replaceBuiltin(slog.Any(slog.SourceKey, &s))
+	if s, ok := a.Value.Any().(*slog.Source); ok {
+		src.Source = *s
+		return src, true
+	}
+	src.File = a.Value.String()
+	return src, a.Key != ""
+}
 
-	if !h.firstLine && !h.lastLine {
-		if key && !a.neasted {
-			text = "╼ " + text
-		} else if key {
-			text = "╼ " + text
-		} else if wraped {
-			text = "  " + text
-		} else {
-			text = "╼ " + text
+func (h *prettyHandler) renderer(buf []byte, width int) prettyRenderer {
+	return prettyRenderer{buf: buf, width: width, colors: h.colors, theme: h.theme, g: h.glyphs, wrap: h.wrap,
+		maxLines: h.maxLines}
+}
+
+// lineWidth returns the column of the right border, writers that are not terminals use
+// defaultPrettyWidth and narrower terminals minPrettyWidth.
+func (h *prettyHandler) lineWidth() int {
+	width, ok := h.width.get()
+	if !ok {
+		width = defaultPrettyWidth
+	}
+	return max(width, minPrettyWidth) - utf8.RuneCountInString(h.glyphs.right)
+}
+
+/*--------------------------------ATTRS------------------------------------------------------*/
+
+// appendResolved appends a with its value resolved and ReplaceAttr applied to every non-group attr.
+// ReplaceAttr sees the attr as logged, maps, structs and JSON strings are expanded only afterwards
+// so a hook that drops or masks a key also hides the values below it.
+func (h *prettyHandler) appendResolved(dst []slog.Attr, groups []string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], a.Key)
 		}
-	} else if h.firstLine {
-		text = string(ident) + string(ident) + text
-	}
-
-	switch true {
-	case wraped && identLevel > 0 && a.lastChild:
-		prefix = prefix + "    "
-		text = prefix + text
-	case wraped && identLevel > 0 && !a.lastChild:
-		prefix = prefix + "┃   "
-		text = prefix + text
-	case a.firstChild && a.lastChild:
-		prefix = prefix + "┗━━━"
-		text = prefix + text
-	case a.firstChild:
-		prefix = prefix + "┣━━━"
-		text = prefix + text
-	case a.lastChild:
-		prefix = prefix + "┗━━━"
-		text = prefix + text
-	case a.neasted:
-		prefix = prefix + "┣━━━"
-		text = prefix + text
-	default:
-		if identLevel > 1 {
-			prefix = runewidth.FillLeft("┣━━━", identLevel*6)
-			text = prefix + text
-		} else {
-			text = strings.Repeat(string(ident), identLevel) + text
+		var children []slog.Attr
+		for _, ga := range a.Value.Group() {
+			children = h.appendResolved(children, groups, ga)
+		}
+		if len(children) == 0 {
+			return dst
+		}
+		if a.Key == "" {
+			return append(dst, children...)
 		}
+		return append(dst, slog.Attr{Key: a.Key, Value: slog.GroupValue(children...)})
+	}
+
+	if h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return dst
 	}
-	if identLevel > 0 {
-		text = text[4:]
+	if k := a.Value.Kind(); k == slog.KindAny || k == slog.KindString && h.values.json {
+		tree := h.values
+		a.Value = tree.expand(a.Value)
 	}
+	return append(dst, a)
+}
+
+// replaceBuiltin applies ReplaceAttr to the time, level, message and source attrs.
+func (h *prettyHandler) replaceBuiltin(a slog.Attr) slog.Attr {
+	if h.opts.ReplaceAttr == nil {
+		return a
+	}
+	a = h.opts.ReplaceAttr(nil, a)
+	a.Value = a.Value.Resolve()
+	return a
+}
 
-	paddingL := 0
-	textLength := runewidth.StringWidth(text)
+/*--------------------------------RENDERER---------------------------------------------------*/
 
-	if textLength%2 != 0 {
-		text = text + "\u202f"
+// scope renders the groups opened with WithGroup, goas[0] is a group. Every WithGroup group is the last
+// node of its parent, the attrs added to it come first and the record attrs are rendered innermost.
+func (p *prettyRenderer) scope(goas []groupOrAttrs, depth int, attrs []slog.Attr) {
+	if !scopeHasAttrs(goas[1:], attrs) {
+		return
 	}
-	paddingL = (h.lWidth - textLength) / 2
-	if paddingL < 0 {
-		paddingL = 0
+	if depth == 0 {
+		p.narrow = p.tooDeep(scopeDepth(goas, attrs))
 	}
-	padding := strings.Repeat(string(spacer), paddingL)
-	if center && !h.lastLine {
+	p.groupLine(goas[0].group, depth, true)
+	depth++
 
-		return padding + text + padding
+	i := 1
+	for ; i < len(goas) && goas[i].group == ""; i++ {
+		more := scopeHasAttrs(goas[i+1:], attrs)
+		for j, a := range goas[i].attrs {
+			p.attr(a, depth, j == len(goas[i].attrs)-1 && !more)
+		}
+	}
+	if i < len(goas) {
+		p.scope(goas[i:], depth, attrs)
+		return
+	}
+	for j, a := range attrs {
+		p.attr(a, depth, j == len(attrs)-1)
+	}
+}
 
+func scopeHasAttrs(goas []groupOrAttrs, attrs []slog.Attr) bool {
+	if len(attrs) > 0 {
+		return true
 	}
-	if h.firstLine {
-		r := runewidth.FillRight(text, h.lWidth)
-		r = strings.ReplaceAll(r, string(' '), string('-'))
-		r = text + r[len(text):]
-		return r
+	for _, goa := range goas {
+		if len(goa.attrs) > 0 {
+			return true
+		}
 	}
-	if h.lastLine {
-		text = fmt.Sprintf("[%s: %s]", "SOURCE", a.attr.Value.String())
+	return false
+}
 
-		if h.lWidth-7 > runewidth.StringWidth(text) {
-			text1 := fmt.Sprintf("[%s: %s]", color.HiWhiteString("SOURCE"), a.attr.Value.String())
-			r := strings.Repeat("-", h.lWidth-7)
-			center := (len(r) - runewidth.StringWidth(text)) / 2
-			result := r[:center] + text1 + r[center+runewidth.StringWidth(text):]
-			return result
-		} else {
-			return a.attr.Value.String()
+// scopeDepth returns the depth of the deepest attr rendered by scope.
+func scopeDepth(goas []groupOrAttrs, attrs []slog.Attr) int {
+	depth, d := 0, 0
+	for _, goa := range goas {
+		if goa.group != "" {
+			depth++
 		}
+		for _, a := range goa.attrs {
+			d = max(d, depth+attrDepth(a))
+		}
+	}
+	for _, a := range attrs {
+		d = max(d, depth+attrDepth(a))
 	}
-	return runewidth.FillRight(text, h.lWidth)
+	return d
 }
 
-func (h *prettyHandler) addIndentSpaces(parts int) string {
-	result := ""
-	for i := range parts {
-		part := runewidth.FillRight("", 6)
-		if h.activeIndent[i] && !h.disableActiveIndent {
-			part = runewidth.FillRight("┃", 6)
+// attrDepth returns the depth of the deepest attr nested in a, zero if a is not a group.
+func attrDepth(a slog.Attr) int {
+	d := 0
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			d = max(d, attrDepth(ga)+1)
 		}
-		result += part
 	}
-	return result
+	return d
+}
+
+// tooDeep reports whether the keys at depth would start past the middle of the line with full rails.
+func (p *prettyRenderer) tooDeep(depth int) bool {
+	return depth > 0 && 6*depth+3 > p.width/2
+}
+
+// tree returns the glyphs of the rails of the current tree.
+func (p *prettyRenderer) tree() *glyphs {
+	if p.narrow {
+		return p.g.narrow
+	}
+	return p.g
+}
+
+// attr renders a resolved attr, last reports whether it is the last node of its parent.
+func (p *prettyRenderer) attr(a slog.Attr, depth int, last bool) {
+	if depth == 0 {
+		p.narrow = p.tooDeep(attrDepth(a))
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		p.groupLine(a.Key, depth, last)
+		for i, ga := range attrs {
+			p.attr(ga, depth+1, i == len(attrs)-1)
+		}
+		return
+	}
+
+	if depth == 0 {
+		p.ascii(p.g.tee)
+	} else {
+		p.ascii(p.g.left)
+		p.branch(depth, last)
+	}
+	p.ascii(p.g.bullet)
+
+	value := a.Value.String()
+	key, keyW := p.fitKey(a.Key, minValueWidth+6)
+	if p.col+keyW+quotedWidth(value)+6 > p.width || strings.Contains(value, "\n") {
+		p.value(key, keyW, value, depth, last)
+		return
+	}
+	if depth == 0 {
+		p.ascii("[")
+	}
+	p.key(depth, key)
+	p.ascii(": ")
+	p.quoted(value)
+	if depth == 0 {
+		p.ascii("] ")
+	}
+	p.endLine(p.g.right)
+}
+
+func (p *prettyRenderer) groupLine(key string, depth int, last bool) {
+	p.ascii(p.g.left)
+	p.branch(depth, last)
+	p.ascii(p.g.bullet)
+	if !p.theme.NoEmoji {
+		p.text("📦 ")
+	}
+	key, _ = p.fitKey(key, 1)
+	p.key(depth, key)
+	p.ascii(":")
+	p.endLine(p.g.right)
 }
 
-func appendInRight(target, text string) string {
-	tl := runewidth.StringWidth(text)
-	if len(target) < len(text)+5 || tl == 0 {
-		return target
+// branch writes the tree rails of the ancestors and the connector of a node at depth.
+func (p *prettyRenderer) branch(depth int, last bool) {
+	p.rails = append(p.rails[:depth], !last)
+	if depth == 0 {
+		return
+	}
+	t := p.tree()
+	p.ascii(t.indent)
+	p.ancestorRails(depth)
+	if last {
+		p.ascii(t.last)
+	} else {
+		p.ascii(t.mid)
 	}
-	modifiablePart := target[:len(target)-tl-5]
-	lastFive := target[len(target)-5:]
-	return modifiablePart + text + lastFive
 }
-func centerString(str string, width int) string {
-	strLen := runewidth.StringWidth(str)
-	if strLen >= width {
-		return str
+
+func (p *prettyRenderer) ancestorRails(depth int) {
+	t := p.tree()
+	for k := 1; k < depth; k++ {
+		if p.rails[k] {
+			p.ascii(t.rail)
+		} else {
+			p.fill(' ', p.col+utf8.RuneCountInString(t.rail))
+		}
 	}
-	empty := width - strLen
-	space := empty / 2
-	remaider := empty % 2
-	if remaider == 0 {
-		return strings.Repeat(string(' '), space) + str + strings.Repeat(string(' '), space)
+}
+
+// fitKey shortens key with … so that reserve columns remain on the line after it, long keys
+// would otherwise push the line past the right border.
+func (p *prettyRenderer) fitKey(key string, reserve int) (string, int) {
+	w := textWidth(key)
+	if room := max(p.width-p.col-reserve, 4); w > room {
+		key = runewidth.Truncate(key, room, "…")
+		w = textWidth(key)
 	}
-	return strings.Repeat(string(' '), space) + str + strings.Repeat(string(' '), space+1)
+	return key, w
 }
-func (h *prettyHandler) wrapLongValue(buf *[]byte, a attrWithInfo, key, value string, lvl int) {
-	vMaxL := h.lWidth - len(a.attr.Key) - 16 - lvl*6
-	if vMaxL%2 != 0 {
-		vMaxL = vMaxL - 1
+
+// value writes a value that does not fit on the line of its key, multi-line values are written as blocks.
+func (p *prettyRenderer) value(key string, keyW int, value string, depth int, last bool) {
+	if strings.Contains(value, "\n") {
+		p.block(key, value, depth, last)
+	} else {
+		p.wrapped(key, keyW, value, depth, last)
 	}
+}
 
-	vals := splitText(value, vMaxL)
-	keyL := len(a.attr.Key)
-	sameSymbol := centerString("\u2e17", keyL)
-	space := max(keyL-runewidth.StringWidth(sameSymbol), 0)
-	key = strings.Repeat(" ", space) + key
+// wrapped writes a value that does not fit the line in chunks, continuation lines are marked with ⸗.
+func (p *prettyRenderer) wrapped(key string, keyW int, value string, depth int, last bool) {
+	chunks := splitWidth(value, max(p.width-p.col-keyW-6, minValueWidth), true)
 
-	for i, v := range vals {
-		var str string
+	for i, v := range chunks {
 		if i == 0 {
-			str = fmt.Sprintf("%s:%q", key, v)
+			p.key(depth, key)
 		} else {
-			str = fmt.Sprintf("%s:%q", colorizeKey(lvl, sameSymbol), v)
+			p.continuation(depth, last)
+			pad := max(keyW-1, 0)
+			p.fill(' ', p.col+pad/2)
+			p.key(depth, p.g.cont)
+			p.fill(' ', p.col+pad-pad/2)
 		}
+		p.ascii(":")
+		p.quoted(v)
+		p.endLine(p.g.right)
+	}
+}
+
+// block writes every line of a multi-line value below its key with tabs expanded, lines wider
+// than the box are wrapped and marked with ⸗.
+func (p *prettyRenderer) block(key, value string, depth int, last bool) {
+	p.key(depth, key)
+	p.ascii(":")
+	p.endLine(p.g.right)
+
+	lines := strings.Split(strings.TrimRight(value, "\n"), "\n")
+	shown := lines
+	if p.maxLines > 0 && len(lines) > p.maxLines {
+		shown = lines[:p.maxLines]
+	}
+	for _, line := range shown {
+		p.continuation(depth, last)
+		chunks := splitWidth(blockLine(line), max(p.width-p.col-3, minValueWidth), false)
+		if len(chunks) == 0 {
+			chunks = []string{""}
+		}
+		for i, chunk := range chunks {
+			if i > 0 {
+				p.endLine(p.g.right)
+				p.continuation(depth, last)
+				p.key(depth, p.g.cont)
+			} else {
+				p.ascii(p.g.gutter)
+			}
+			p.ascii(" ")
+			p.text(chunk)
+		}
+		p.endLine(p.g.right)
+	}
+	if hidden := len(lines) - len(shown); hidden > 0 {
+		p.continuation(depth, last)
+		p.ascii(p.g.gutter)
+		p.ascii(" … ")
+		p.ascii(strconv.Itoa(hidden))
+		p.ascii(" more lines")
+		p.endLine(p.g.right)
+	}
+}
+
+// continuation writes the start of a continuation line of the node at depth up to its key.
+func (p *prettyRenderer) continuation(depth int, last bool) {
+	p.ascii(p.g.left)
+	if depth > 0 {
+		t := p.tree()
+		p.ascii(t.indent)
+		p.ancestorRails(depth)
+		if last {
+			p.fill(' ', p.col+utf8.RuneCountInString(t.last))
+		} else {
+			p.ascii(t.stem)
+		}
+	}
+	p.fill(' ', p.col+utf8.RuneCountInString(p.g.bullet))
+}
 
-		str = h.alignValues(str, lvl, ' ', '─', false, a, false, i > 0)
+func (p *prettyRenderer) footer(src prettySource) {
+	src = src.fit(p.width - 2 - len("[SOURCE: ]"))
+	sw := len("[SOURCE: ]") + src.width()
 
-		*buf = fmt.Append(*buf, "│", str, "│")
-		if i < len(vals)-1 {
-			*buf = fmt.Append(*buf, "\n")
+	p.ascii(p.g.bottomLeft)
+	p.fill(p.g.rule, max((p.width-sw)/2, p.col))
+	p.ascii("[")
+	p.colored(p.theme.Source, "SOURCE")
+	p.ascii(": ")
+	p.source(src, ColorNone)
+	p.ascii("]")
+	if p.g.bottomRight != "" {
+		p.fill(p.g.rule, p.width)
+	}
+	p.endLine(p.g.bottomRight)
+}
+
+// prettySource is a source location with the hyperlink to its file.
+type prettySource struct {
+	slog.Source
+	link string
+}
+
+// width returns the width of "file:line function".
+func (s prettySource) width() int {
+	w := textWidth(s.File)
+	if s.Line > 0 {
+		w += 1 + len(strconv.Itoa(s.Line))
+	}
+	if s.Function != "" {
+		w += 1 + textWidth(s.Function)
+	}
+	return w
+}
+
+// fit shortens the function from the right and then the path from the left until the source is at
+// most width columns wide, functions narrower than two columns are dropped.
+func (s prettySource) fit(width int) prettySource {
+	if w := s.width(); w > width && s.Function != "" {
+		room := textWidth(s.Function) - (w - width)
+		if room < 2 {
+			s.Function = ""
+		} else {
+			s.Function = runewidth.Truncate(s.Function, room, "…")
 		}
 	}
+	fileW := textWidth(s.File)
+	if room := fileW - (s.width() - width); room < fileW && room > 1 {
+		s.File = "…" + runewidth.TruncateLeft(s.File, fileW-room+1, "")
+	}
+	return s
+}
+
+// source writes "file:line function" in color c, wrapped in an OSC 8 hyperlink if src has a link.
+func (p *prettyRenderer) source(src prettySource, c Color) {
+	if src.link != "" {
+		p.buf = append(p.buf, "\x1b]8;;"...)
+		p.buf = append(p.buf, src.link...)
+		p.buf = append(p.buf, "\x1b\\"...)
+	}
+	p.setColor(c)
+	p.text(src.File)
+	if src.Line > 0 {
+		n := len(p.buf)
+		p.buf = strconv.AppendInt(append(p.buf, ':'), int64(src.Line), 10)
+		p.col += len(p.buf) - n
+	}
+	p.resetColor(c)
+	if src.link != "" {
+		p.buf = append(p.buf, "\x1b]8;;\x1b\\"...)
+	}
+	if src.Function != "" {
+		p.ascii(" ")
+		p.colored(c, src.Function)
+	}
+}
+
+func (p *prettyRenderer) key(depth int, key string) {
+	p.colored(p.theme.keyColor(depth), key)
+}
+
+/*--------------------------------UTILS------------------------------------------------------*/
+
+// ascii writes s, every rune of s must be one column wide.
+func (p *prettyRenderer) ascii(s string) {
+	p.buf = append(p.buf, s...)
+	p.col += utf8.RuneCountInString(s)
+}
+
+func (p *prettyRenderer) text(s string) {
+	p.buf = append(p.buf, s...)
+	p.col += textWidth(s)
+}
+
+func (p *prettyRenderer) quoted(s string) {
+	n := len(p.buf)
+	p.buf = strconv.AppendQuote(p.buf, s)
+	p.col += textWidth(string(p.buf[n:]))
+}
+
+func (p *prettyRenderer) colored(c Color, s string) {
+	p.setColor(c)
+	p.text(s)
+	p.resetColor(c)
 }
 
-func splitText(text string, maxLength int) []string {
-	var result []string
-	index := 0
-	str := []byte(text)
+func (p *prettyRenderer) setColor(c Color) {
+	if p.colors {
+		p.buf = append(p.buf, c...)
+	}
+}
 
-	for index < len(str) {
-		next := cutString(str, maxLength, index)
-		result = append(result, string(str[index:next]))
-		index = next
+func (p *prettyRenderer) resetColor(c Color) {
+	if p.colors && c != ColorNone {
+		p.buf = append(p.buf, colorReset...)
 	}
+}
 
-	return result
+// fill writes c until the line is col columns wide.
+func (p *prettyRenderer) fill(c byte, col int) {
+	for ; p.col < col; p.col++ {
+		p.buf = append(p.buf, c)
+	}
 }
 
-func cutString(buf []byte, max, index int) int {
-	if max <= 0 || index >= len(buf) {
-		return len(buf)
+// endLine pads the line to the right border, lines without a border are not padded.
+func (p *prettyRenderer) endLine(border string) {
+	if border != "" {
+		p.fill(' ', p.width)
 	}
-	end := index + max
-	if end >= len(buf) {
-		return len(buf)
+	p.buf = append(p.buf, border...)
+	p.buf = append(p.buf, '\n')
+	p.col = 0
+}
+
+// textWidth returns the display width of s, with a fast path for ASCII.
+func textWidth(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return runewidth.StringWidth(s)
+		}
 	}
+	return len(s)
+}
 
-	delimiters := []byte{' ', '/', '.', ',', '\n'}
-	bestIndex := -1
-	for _, delim := range delimiters {
-		if idx := bytes.LastIndexByte(buf[index:end], delim); idx > bestIndex {
-			bestIndex = idx
+func quotedWidth(s string) int {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= utf8.RuneSelf || c < ' ' || c == '"' || c == '\\' || c == 0x7f {
+			return textWidth(strconv.Quote(s))
 		}
 	}
+	return len(s) + 2
+}
 
-	if bestIndex == -1 {
-		return end
+// splitWidth splits s into chunks at most width columns wide, preferring cuts after a space or one
+// of "/.," that follows other text. Runes are never split, quoted measures runes as strconv.Quote
+// writes them. Unquoted chunks of only spaces are dropped unless they end s, so an indentation
+// wider than width does not leave empty lines.
+func splitWidth(s string, width int, quoted bool) []string {
+	var chunks []string
+	for len(s) > 0 {
+		cut, brk, w := 0, 0, 0
+		text := false
+		for cut < len(s) {
+			r, size := utf8.DecodeRuneInString(s[cut:])
+			rw := runeWidth(r, size, quoted)
+			if w+rw > width && cut > 0 {
+				break
+			}
+			w += rw
+			cut += size
+			if r != ' ' {
+				text = true
+			}
+			if text && (r == ' ' || r == '/' || r == '.' || r == ',') {
+				brk = cut
+			}
+		}
+		if cut < len(s) && brk > 0 {
+			cut = brk
+		}
+		if !quoted && !text && cut < len(s) {
+			s = s[cut:]
+			continue
+		}
+		chunks = append(chunks, s[:cut])
+		s = s[cut:]
 	}
-	return index + bestIndex + 1
+	return chunks
 }
-func colorizeLevel(level slog.Level) string {
-	colorMap := map[slog.Level]string{
-		slog.LevelDebug: color.HiMagentaString("🔧 " + level.String()),
-		slog.LevelInfo:  color.HiBlueString("🌐 " + level.String()),
-		slog.LevelWarn:  color.HiYellowString("⚠️  " + level.String()),
-		slog.LevelError: color.HiRedString("🛑 " + level.String()),
+
+func runeWidth(r rune, size int, quoted bool) int {
+	switch {
+	case r == utf8.RuneError && size == 1:
+		if quoted {
+			return len(`\xff`)
+		}
+		return 1
+	case quoted && (r == '"' || r == '\\'):
+		return 2
+	case quoted && !strconv.IsPrint(r):
+		return len(strconv.QuoteRune(r)) - 2
+	default:
+		return runewidth.RuneWidth(r)
 	}
-	return colorMap[level]
 }
 
-func colorizeKey(indentLevel int, key string) string {
-	idx := indentLevel % len(keyColors)
-	return keyColors[idx](key)
+// blockLine expands the tabs of a line of a multi-line value and escapes other control characters.
+func blockLine(line string) string {
+	line = strings.TrimSuffix(line, "\r")
+	if !strings.ContainsFunc(line, unicode.IsControl) {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		switch {
+		case r == '\t':
+			n := tabWidth - col%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+		case unicode.IsControl(r):
+			q := strconv.QuoteRune(r)
+			b.WriteString(q[1 : len(q)-1])
+			col += len(q) - 2
+		default:
+			b.WriteRune(r)
+			col += runewidth.RuneWidth(r)
+		}
+	}
+	return b.String()
 }
 
 /*--------------------------------slog methods-----------------------------------------------*/
@@ -479,20 +998,35 @@ func (h *prettyHandler) WithGroup(name string) slog.Handler {
 	if name == "" {
 		return h
 	}
-	return h.withGroupOrAttrs(groupOrAttrs{group: name})
+	h2 := *h
+	h2.goas = appendGroupOrAttrs(h.goas, groupOrAttrs{group: name})
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &h2
 }
 
+// WithAttrs resolves and replaces attrs once, attrs added outside of groups are also rendered
+// for the current terminal width.
 func (h *prettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	if len(attrs) == 0 {
+	var resolved []slog.Attr
+	for _, a := range attrs {
+		resolved = h.appendResolved(resolved, h.groups, a)
+	}
+	if len(resolved) == 0 {
 		return h
 	}
-	return h.withGroupOrAttrs(groupOrAttrs{attrs: attrs})
-}
 
-func (h *prettyHandler) withGroupOrAttrs(goa groupOrAttrs) *prettyHandler {
 	h2 := *h
-	h2.goas = make([]groupOrAttrs, len(h.goas)+1)
-	copy(h2.goas, h.goas)
-	h2.goas[len(h2.goas)-1] = goa
+	if len(h.goas) > 0 {
+		h2.goas = appendGroupOrAttrs(h.goas, groupOrAttrs{attrs: resolved})
+		return &h2
+	}
+
+	h2.rootAttrs = append(h.rootAttrs[:len(h.rootAttrs):len(h.rootAttrs)], resolved...)
+	h2.preWidth = h.lineWidth()
+	p := h.renderer(nil, h2.preWidth)
+	for _, a := range h2.rootAttrs {
+		p.attr(a, 0, false)
+	}
+	h2.pre = p.buf
 	return &h2
 }
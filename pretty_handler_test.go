@@ -0,0 +1,404 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+func TestPrettyHandlerSlogtest(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	var buf bytes.Buffer
+	slogtest.Run(t, func(*testing.T) slog.Handler {
+		buf.Reset()
+		return NewPrettyHandler(&buf, nil, PrettyWithLayout(LayoutCompact))
+	}, func(t *testing.T) map[string]any {
+		return parseCompact(t, buf.String())
+	})
+}
+
+// parseCompact parses one compact line without colors and source: an optional time, the level,
+// a message without spaces or quoted and group-qualified key=value pairs.
+func parseCompact(t *testing.T, out string) map[string]any {
+	t.Helper()
+	line, ok := strings.CutSuffix(out, "\n")
+	if !ok || strings.Contains(line, "\n") {
+		t.Fatalf("want one line, got %q", out)
+	}
+
+	m := map[string]any{}
+	if len(line) > len(compactTimeLayout) && line[len(compactTimeLayout)] == ' ' {
+		if _, err := time.Parse(compactTimeLayout, line[:len(compactTimeLayout)]); err == nil {
+			m[slog.TimeKey] = line[:len(compactTimeLayout)]
+			line = line[len(compactTimeLayout)+1:]
+		}
+	}
+	if len(line) < compactLevelWidth+1 {
+		t.Fatalf("line %q has no level", out)
+	}
+	m[slog.LevelKey] = strings.TrimSpace(line[:compactLevelWidth])
+	line = line[compactLevelWidth+1:]
+
+	m[slog.MessageKey], line = compactToken(t, line)
+	for line != "" {
+		line = strings.TrimPrefix(line, " ")
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			t.Fatalf("pair without = in %q", out)
+		}
+		var value string
+		value, line = compactToken(t, rest)
+
+		path := strings.Split(key, ".")
+		group := m
+		for _, g := range path[:len(path)-1] {
+			sub, ok := group[g].(map[string]any)
+			if !ok {
+				sub = map[string]any{}
+				group[g] = sub
+			}
+			group = sub
+		}
+		group[path[len(path)-1]] = value
+	}
+	return m
+}
+
+// compactToken returns the quoted or space-delimited token at the start of s and the rest.
+func compactToken(t *testing.T, s string) (string, string) {
+	t.Helper()
+	if strings.HasPrefix(s, `"`) {
+		q, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			t.Fatalf("token %q: %v", s, err)
+		}
+		v, _ := strconv.Unquote(q)
+		return v, s[len(q):]
+	}
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], s[i:]
+	}
+	return s, ""
+}
+
+// TestPrettyHandlerConcurrent derives and uses loggers of one handler from many goroutines,
+// it is meant to run with -race.
+func TestPrettyHandlerConcurrent(t *testing.T) {
+	const goroutines, records = 16, 200
+
+	var buf bytes.Buffer
+	layout := &PrettyLayoutVar{}
+	h := NewPrettyHandler(&buf, &HandlerOptions{AddSource: true},
+		PrettyWithLayout(layout),
+		PrettyWithTime(PrettyTime{Delta: true}),
+	)
+	base := slog.New(h).With("service", "api")
+
+	var wg sync.WaitGroup
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := base
+			for i := range records {
+				switch i % 4 {
+				case 0:
+					l = base.With("worker", g)
+				case 1:
+					l = l.WithGroup("req").With("id", i)
+				case 2:
+					layout.Toggle()
+				case 3:
+					l = slog.New(h.WithGroup("shared").WithAttrs([]slog.Attr{slog.Int("worker", g)}))
+				}
+				l.Info("stress", "i", i, "user", map[string]any{"id": g, "tags": []string{"a", "b"}})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := strings.Count(buf.String(), "stress"); n != goroutines*records {
+		t.Fatalf("%d records written, want %d", n, goroutines*records)
+	}
+}
+
+// benchmarkPretty logs a record with three attributes through a logger with four attributes and
+// AddSource. Timings depend on the machine, compare revisions on one machine with benchstat.
+func benchmarkPretty(b *testing.B, opts ...PrettyOption) {
+	l := slog.New(NewPrettyHandler(io.Discard, &HandlerOptions{AddSource: true}, opts...)).With(
+		"service", "api",
+		"version", "1.2.3",
+		slog.Group("host", "name", "web-1", "zone", "eu"),
+	)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		l.LogAttrs(ctx, slog.LevelInfo, "request handled",
+			slog.String("path", "/api/v1/users"),
+			slog.Int("status", 200),
+			slog.Group("user", slog.Int("id", 42), slog.String("name", "bob")),
+		)
+	}
+}
+
+func BenchmarkPrettyBoxed(b *testing.B) {
+	benchmarkPretty(b)
+}
+
+func BenchmarkPrettyCompact(b *testing.B) {
+	benchmarkPretty(b, PrettyWithLayout(LayoutCompact))
+}
+
+func BenchmarkPrettyValues(b *testing.B) {
+	l := slog.New(NewPrettyHandler(io.Discard, nil))
+	payload := map[string]any{"id": 42, "roles": []string{"admin", "dev"}, "meta": map[string]int{"a": 1, "b": 2}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		l.Info("user loaded", "user", payload)
+	}
+}
+
+func TestPrettyHandlerReplaceAttrBeforeExpand(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	var buf bytes.Buffer
+	var seen []string
+	redact := func(groups []string, a slog.Attr) slog.Attr {
+		switch a.Key {
+		case slog.TimeKey, slog.LevelKey, slog.MessageKey, slog.SourceKey:
+			return a
+		}
+		seen = append(seen, strings.Join(append(groups, a.Key), "."))
+		if a.Key == "secret" || a.Key == "body" {
+			return slog.String(a.Key, "[REDACTED]")
+		}
+		return a
+	}
+	for _, layout := range []PrettyLayout{LayoutBoxed, LayoutCompact} {
+		buf.Reset()
+		seen = nil
+		l := slog.New(NewPrettyHandler(&buf, &HandlerOptions{ReplaceAttr: redact},
+			PrettyWithLayout(layout),
+			PrettyWithExpandJSON(true),
+		))
+		l.Info("login",
+			"secret", map[string]string{"pw": "hunter2"},
+			"body", `{"a":"hunter2"}`,
+			slog.Group("req", "user", struct{ ID int }{7}),
+		)
+
+		out := buf.String()
+		if strings.Contains(out, "hunter2") {
+			t.Fatalf("%s: redacted value leaked:\n%s", layout, out)
+		}
+		if strings.Count(out, "[REDACTED]") != 2 {
+			t.Fatalf("%s: want two masked values:\n%s", layout, out)
+		}
+		if got, want := strings.Join(seen, ","), "secret,body,req.user"; got != want {
+			t.Fatalf("%s: ReplaceAttr saw %s, want %s", layout, got, want)
+		}
+		if !strings.Contains(out, "ID") {
+			t.Fatalf("%s: struct not expanded after ReplaceAttr:\n%s", layout, out)
+		}
+	}
+}
+
+// boxedBody returns the lines of a boxed record below its header with the padding and right border
+// trimmed.
+func boxedBody(t *testing.T, out string) []string {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if !strings.HasPrefix(lines[0], "╭") {
+		t.Fatalf("record without a header:\n%s", out)
+	}
+	body := lines[1:]
+	for i, line := range body {
+		body[i] = strings.TrimRight(strings.TrimSuffix(line, "│"), " ")
+	}
+	return body
+}
+
+func TestPrettyHandlerBoxedGroups(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	var buf bytes.Buffer
+	var seen []string
+	mask := func(groups []string, a slog.Attr) slog.Attr {
+		if groups == nil && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+			return a
+		}
+		path := strings.Join(append(groups, a.Key), ".")
+		seen = append(seen, path)
+		switch {
+		case path == "req.user.name":
+			return slog.String(a.Key, "***")
+		case a.Key == "token":
+			return slog.Attr{}
+		}
+		return a
+	}
+	l := slog.New(NewPrettyHandler(&buf, &HandlerOptions{ReplaceAttr: mask}, PrettyWithWidth(60)))
+
+	tests := []struct {
+		name string
+		log  func()
+		want []string
+	}{
+		{
+			"nested",
+			func() {
+				l.With("service", "api").WithGroup("req").With("id", "7").Info("hello",
+					slog.Group("user", "name", "bob", "token", "t", slog.Group("addr", "city", "Oslo")),
+				)
+			},
+			[]string{
+				`├╼ [service: "api"]`,
+				`│╼ 📦 req:`,
+				`│  ┣━━━╼ id: "7"`,
+				`│  ┗━━━╼ 📦 user:`,
+				`│        ┣━━━╼ name: "***"`,
+				`│        ┗━━━╼ 📦 addr:`,
+				`│              ┗━━━╼ city: "Oslo"`,
+			},
+		},
+		{
+			"empty groups",
+			func() {
+				l.WithGroup("empty").Info("hello", slog.Group("none"), slog.Group("dropped", "token", "t"))
+			},
+			nil,
+		},
+		{
+			"with groups",
+			func() { l.WithGroup("a").WithGroup("b").Info("hello", "k", 1) },
+			[]string{
+				`│╼ 📦 a:`,
+				`│  ┗━━━╼ 📦 b:`,
+				`│        ┗━━━╼ k: "1"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		tt.log()
+		if got := boxedBody(t, buf.String()); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+
+	want := "service,req.id,req.user.name,req.user.token,req.user.addr.city,empty.dropped.token,a.b.k"
+	if got := strings.Join(seen, ","); got != want {
+		t.Fatalf("ReplaceAttr saw %s, want %s", got, want)
+	}
+}
+
+// logNarrow logs a record with deep groups, long keys and values and a long source through a
+// handler without time.
+func logNarrow(w io.Writer, opts ...PrettyOption) {
+	src := &slog.Source{Function: "github.com/acme/service/internal/api.(*Server).ServeHTTP", File: "/src/internal/api/server.go", Line: 123}
+	fixed := func(groups []string, a slog.Attr) slog.Attr {
+		switch {
+		case groups != nil:
+		case a.Key == slog.TimeKey:
+			return slog.Attr{}
+		case a.Key == slog.SourceKey:
+			return slog.Any(slog.SourceKey, src)
+		}
+		return a
+	}
+	l := slog.New(NewPrettyHandler(w, &HandlerOptions{AddSource: true, ReplaceAttr: fixed}, opts...))
+	l.WithGroup("request").WithGroup("authentication").Info("session refreshed",
+		"a_really_long_attribute_key_name", "value",
+		slog.Group("user", slog.Group("profile", slog.Group("address", "street_name_and_number", "Karl Johans gate 22, Oslo"))),
+		"stack", "goroutine 1 [running]:\n\tmain.main()",
+	)
+}
+
+func TestPrettyHandlerNarrow(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	tests := []struct {
+		layout PrettyLayout
+		want   string
+	}{
+		{LayoutBoxed, `
+╭──[🌐 INFO: "session refreshed"]------╮
+│╼ 📦 request:                         │
+│ ┗╼ 📦 authentication:                │
+│   ┣╼ a_really_long_att…: "value"     │
+│   ┣╼ 📦 user:                        │
+│   ┃ ┗╼ 📦 profile:                   │
+│   ┃   ┗╼ 📦 address:                 │
+│   ┃     ┗╼ street_name…:"Karl "      │
+│   ┃             ⸗      :"Johans "    │
+│   ┃             ⸗      :"gate 22,"   │
+│   ┃             ⸗      :" Oslo"      │
+│   ┗╼ stack:                          │
+│      ┆ goroutine 1 [running]:        │
+│      ┆     main.main()               │
+╰[SOURCE: …internal/api/server.go:123]-╯
+`},
+		{LayoutCompact, `
+INFO  session refreshed request.authent…
+server.go:123 github.com/acme/service/i…
+`},
+	}
+	var buf bytes.Buffer
+	for _, tt := range tests {
+		buf.Reset()
+		logNarrow(&buf, PrettyWithWidth(40), PrettyWithLayout(tt.layout))
+		if got := "\n" + buf.String(); got != tt.want {
+			t.Errorf("%s: got%s\nwant%s", tt.layout, got, tt.want)
+		}
+	}
+
+	for _, layout := range []PrettyLayout{LayoutBoxed, LayoutCompact} {
+		for width := minPrettyWidth; width <= 80; width++ {
+			buf.Reset()
+			logNarrow(&buf, PrettyWithWidth(width), PrettyWithLayout(layout))
+			for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+				if w := runewidth.StringWidth(line); w > width {
+					t.Fatalf("%s width %d: line is %d columns wide:\n%s", layout, width, w, buf.String())
+				}
+			}
+		}
+	}
+}
+
+func TestSplitWidth(t *testing.T) {
+	tests := []struct {
+		s      string
+		width  int
+		quoted bool
+		want   []string
+	}{
+		{"hello world", 8, false, []string{"hello ", "world"}},
+		{"/var/log/app.log", 10, false, []string{"/var/log/", "app.log"}},
+		{"    line2_long_word", 10, false, []string{"    line2_", "long_word"}},
+		{"    FROM users", 10, false, []string{"    FROM ", "users"}},
+		{strings.Repeat(" ", 12) + "x", 8, false, []string{"    x"}},
+		{strings.Repeat(" ", 12), 8, false, []string{"    "}},
+		{"        ab", 8, true, []string{"        ", "ab"}},
+		{"ééééé", 4, false, []string{"éééé", "é"}},
+		{`a"b"c`, 4, true, []string{`a"b`, `"c`}},
+	}
+	for _, tt := range tests {
+		if got := splitWidth(tt.s, tt.width, tt.quoted); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("splitWidth(%q, %d, %t) = %q, want %q", tt.s, tt.width, tt.quoted, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package logger
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Color is an SGR escape sequence used by PrettyTheme, the empty Color leaves text uncolored.
+type Color string
+
+const (
+	colorReset = "\x1b[0m"
+
+	ColorNone      Color = ""
+	ColorBlack     Color = "\x1b[30m"
+	ColorRed       Color = "\x1b[31m"
+	ColorGreen     Color = "\x1b[32m"
+	ColorYellow    Color = "\x1b[33m"
+	ColorBlue      Color = "\x1b[34m"
+	ColorMagenta   Color = "\x1b[35m"
+	ColorCyan      Color = "\x1b[36m"
+	ColorWhite     Color = "\x1b[37m"
+	ColorHiBlack   Color = "\x1b[90m"
+	ColorHiRed     Color = "\x1b[91m"
+	ColorHiGreen   Color = "\x1b[92m"
+	ColorHiYellow  Color = "\x1b[93m"
+	ColorHiBlue    Color = "\x1b[94m"
+	ColorHiMagenta Color = "\x1b[95m"
+	ColorHiCyan    Color = "\x1b[96m"
+	ColorHiWhite   Color = "\x1b[97m"
+)
+
+// Color256 returns the foreground color n of the 256-color palette.
+func Color256(n uint8) Color {
+	return Color("\x1b[38;5;" + strconv.Itoa(int(n)) + "m")
+}
+
+// Bold returns c in bold.
+func (c Color) Bold() Color {
+	return "\x1b[1m" + c
+}
+
+// GlyphSet selects the characters of the pretty handler box and tree.
+type GlyphSet int
+
+const (
+	GlyphsRounded GlyphSet = iota
+	GlyphsUnicode
+	GlyphsASCII
+	GlyphsNone
+)
+
+// glyphs are the box and tree characters, every glyph is one column wide per rune.
+type glyphs struct {
+	topLeft, topRight       string
+	bottomLeft, bottomRight string
+	left, right, tee        string
+	rule                    byte
+	lead, bullet            string
+	indent                  string
+	rail, mid, last, stem   string
+	cont, gutter            string
+	// narrow has one column rails for trees too deep for the line width.
+	narrow *glyphs
+}
+
+var glyphSets = withNarrow(map[GlyphSet]*glyphs{
+	GlyphsRounded: {
+		topLeft: "╭", topRight: "╮", bottomLeft: "╰", bottomRight: "╯",
+		left: "│", right: "│", tee: "├", rule: '-', lead: "──", bullet: "╼ ", indent: "  ",
+		rail: "┃     ", mid: "┣━━━", last: "┗━━━", stem: "┃   ", cont: "⸗", gutter: "┆",
+	},
+	GlyphsUnicode: {
+		topLeft: "┌", topRight: "┐", bottomLeft: "└", bottomRight: "┘",
+		left: "│", right: "│", tee: "├", rule: '-', lead: "──", bullet: "╼ ", indent: "  ",
+		rail: "│     ", mid: "├───", last: "└───", stem: "│   ", cont: "⸗", gutter: "┆",
+	},
+	GlyphsASCII: {
+		topLeft: "+", topRight: "+", bottomLeft: "+", bottomRight: "+",
+		left: "|", right: "|", tee: "+", rule: '-', lead: "--", bullet: "> ", indent: "  ",
+		rail: "|     ", mid: "|---", last: "`---", stem: "|   ", cont: "~", gutter: ":",
+	},
+	GlyphsNone: {
+		rule: ' ', lead: "", bullet: "", indent: "  ",
+		rail: "      ", mid: "    ", last: "    ", stem: "    ", cont: "~", gutter: " ",
+	},
+})
+
+// withNarrow adds the narrow rails to every set, they keep the first rune of each rail glyph.
+func withNarrow(sets map[GlyphSet]*glyphs) map[GlyphSet]*glyphs {
+	for _, g := range sets {
+		n := *g
+		n.indent = " "
+		n.rail = firstRune(g.rail) + " "
+		n.mid, n.last, n.stem = firstRune(g.mid), firstRune(g.last), firstRune(g.stem)
+		g.narrow = &n
+	}
+	return sets
+}
+
+func firstRune(s string) string {
+	_, size := utf8.DecodeRuneInString(s)
+	return s[:size]
+}
+
+// PrettyTheme sets the colors, glyphs and emoji of the pretty handler.
+type PrettyTheme struct {
+	// Levels colors the level badge, levels are rounded down to Debug, Info, Warn and Error.
+	Levels map[slog.Level]Color
+	// Keys colors keys by depth, the list is repeated for deeper levels.
+	Keys []Color
+	// Time and Source color the timestamp and the source label.
+	Time   Color
+	Source Color
+	Glyphs GlyphSet
+	// NoEmoji drops the level, group and time emoji.
+	NoEmoji bool
+}
+
+var (
+	// DarkTheme is the default theme, bright colors for dark backgrounds.
+	DarkTheme = PrettyTheme{
+		Levels: map[slog.Level]Color{
+			slog.LevelDebug: ColorHiMagenta,
+			slog.LevelInfo:  ColorHiBlue,
+			slog.LevelWarn:  ColorHiYellow,
+			slog.LevelError: ColorHiRed,
+		},
+		Keys:   []Color{ColorHiGreen, ColorHiYellow, ColorHiCyan, ColorHiMagenta, ColorHiRed},
+		Time:   ColorHiBlack,
+		Source: ColorHiWhite,
+	}
+
+	// LightTheme uses the normal intensity colors that stay readable on light backgrounds.
+	LightTheme = PrettyTheme{
+		Levels: map[slog.Level]Color{
+			slog.LevelDebug: ColorMagenta,
+			slog.LevelInfo:  ColorBlue,
+			slog.LevelWarn:  Color256(130),
+			slog.LevelError: ColorRed,
+		},
+		Keys:   []Color{ColorBlue, ColorMagenta, ColorGreen, Color256(130), ColorCyan},
+		Time:   Color256(242),
+		Source: ColorBlack,
+	}
+
+	// HighContrastTheme tells levels apart by background and weight instead of hue alone,
+	// keys alternate between bold white and bold cyan.
+	HighContrastTheme = PrettyTheme{
+		Levels: map[slog.Level]Color{
+			slog.LevelDebug: ColorCyan.Bold(),
+			slog.LevelInfo:  ColorHiWhite.Bold(),
+			slog.LevelWarn:  "\x1b[1;30;103m",
+			slog.LevelError: "\x1b[1;97;41m",
+		},
+		Keys:   []Color{ColorHiWhite.Bold(), ColorHiCyan.Bold()},
+		Time:   ColorWhite,
+		Source: ColorHiWhite.Bold(),
+		Glyphs: GlyphsUnicode,
+	}
+)
+
+// levelColor returns the color of l rounded down to a standard level.
+func (t *PrettyTheme) levelColor(l slog.Level) Color {
+	return t.Levels[standardLevel(l)]
+}
+
+func (t *PrettyTheme) keyColor(depth int) Color {
+	if len(t.Keys) == 0 {
+		return ColorNone
+	}
+	return t.Keys[depth%len(t.Keys)]
+}
+
+func standardLevel(l slog.Level) slog.Level {
+	switch {
+	case l < slog.LevelInfo:
+		return slog.LevelDebug
+	case l < slog.LevelWarn:
+		return slog.LevelInfo
+	case l < slog.LevelError:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// colorsEnabled reports whether colors are written to out: NO_COLOR disables them, FORCE_COLOR
+// enables them, otherwise they are used only for terminals that process ANSI escapes. On Windows
+// the virtual terminal mode of the console is switched on for that.
+func colorsEnabled(out io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := out.(*os.File)
+	tty := ok && term.IsTerminal(int(f.Fd()))
+	if v := os.Getenv("FORCE_COLOR"); v != "" && v != "0" && v != "false" {
+		if tty {
+			enableVirtualTerminal(f)
+		}
+		return true
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return tty && enableVirtualTerminal(f)
+}
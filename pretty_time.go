@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"log/slog"
+	"time"
+)
+
+// processStart is the reference of PrettyTime.Elapsed.
+var processStart = time.Now()
+
+// PrettyTime sets the timestamp of the pretty handler.
+type PrettyTime struct {
+	// Layout formats the time, time.Stamp in boxes and 15:04:05.000 in compact lines by default.
+	// Any layout of the time package works, such as time.RFC3339Nano or time.Kitchen.
+	Layout string
+	// Location converts the time, time.UTC for example, the record time is used unchanged if nil.
+	Location *time.Location
+	// Elapsed shows the time since the process started instead of the clock.
+	Elapsed bool
+	// Delta adds the time since the previous record of the handler and the loggers derived from it.
+	Delta bool
+}
+
+// appendStamp appends the time value t formatted with layout, the delta to the previous record is
+// appended after it when enabled.
+func (h *prettyHandler) appendStamp(dst []byte, t slog.Value, layout string) []byte {
+	if t.Kind() != slog.KindTime {
+		return append(dst, t.String()...)
+	}
+	tm := t.Time()
+	if h.timeFmt.Elapsed {
+		dst = append(dst, roundDuration(tm.Sub(processStart)).String()...)
+	} else {
+		if h.timeFmt.Location != nil {
+			tm = tm.In(h.timeFmt.Location)
+		}
+		if h.timeFmt.Layout != "" {
+			layout = h.timeFmt.Layout
+		}
+		dst = tm.AppendFormat(dst, layout)
+	}
+	if h.timeFmt.Delta {
+		dst = append(dst, " +"...)
+		if prev := h.last.Swap(tm.UnixNano()); prev != 0 {
+			dst = append(dst, roundDuration(time.Duration(tm.UnixNano()-prev)).String()...)
+		} else {
+			dst = append(dst, '0')
+		}
+	}
+	return dst
+}
+
+// roundDuration keeps three decimals of the unit of d.
+func roundDuration(d time.Duration) time.Duration {
+	switch a := max(d, -d); {
+	case a >= time.Second:
+		return d.Round(time.Millisecond)
+	case a >= time.Millisecond:
+		return d.Round(time.Microsecond)
+	default:
+		return d
+	}
+}
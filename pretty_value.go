@@ -0,0 +1,261 @@
+package logger
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxDepth    = 5
+	defaultMaxElements = 20
+)
+
+// valueTree expands maps, slices, arrays and structs into groups so they are rendered as tree nodes.
+type valueTree struct {
+	maxDepth, maxElems int
+	json               bool
+	// path holds the pointers of the containers being expanded to detect cycles.
+	path []uintptr
+}
+
+// expand returns v as a group if it holds a map, slice, array or struct, or a JSON object or
+// array string when json is set. Other values are returned unchanged.
+func (t *valueTree) expand(v slog.Value) slog.Value {
+	switch v.Kind() {
+	case slog.KindAny:
+		x := v.Any()
+		if raw, ok := x.(json.RawMessage); ok {
+			if t.json {
+				return t.jsonValue(string(raw), v)
+			}
+			return v
+		}
+		if leaf(x) {
+			return v
+		}
+		rv := reflect.ValueOf(x)
+		for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+		switch rv.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+			return t.node(reflect.ValueOf(x), 0)
+		}
+	case slog.KindString:
+		if t.json {
+			return t.jsonValue(v.String(), v)
+		}
+	}
+	return v
+}
+
+func (t *valueTree) jsonValue(s string, v slog.Value) slog.Value {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || (s[0] != '{' && s[0] != '[') {
+		return v
+	}
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	var x any
+	if err := d.Decode(&x); err != nil || d.More() {
+		return v
+	}
+	return t.node(reflect.ValueOf(x), 0)
+}
+
+// node returns the value of rv, containers deeper than maxDepth, repeated on the path or without
+// exported fields are summarized as strings so they are not expanded again.
+func (t *valueTree) node(rv reflect.Value, depth int) slog.Value {
+	n := len(t.path)
+	defer func() { t.path = t.path[:n] }()
+
+	for rv.Kind() == reflect.Interface || rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return slog.StringValue("<nil>")
+		}
+		if rv.Kind() == reflect.Pointer {
+			if rv.CanInterface() && leaf(rv.Interface()) {
+				return slog.AnyValue(rv.Interface()).Resolve()
+			}
+			if t.onPath(rv.Pointer()) {
+				return slog.StringValue("<cycle " + rv.Type().String() + ">")
+			}
+			t.path = append(t.path, rv.Pointer())
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return slog.StringValue("<nil>")
+	}
+	if rv.CanInterface() && leaf(rv.Interface()) {
+		return slog.AnyValue(rv.Interface()).Resolve()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return bytesValue(rv.Bytes())
+		}
+		if rv.IsNil() || rv.Len() == 0 {
+			return slog.StringValue(summary(rv))
+		}
+		if t.onPath(rv.Pointer()) {
+			return slog.StringValue("<cycle " + rv.Type().String() + ">")
+		}
+		t.path = append(t.path, rv.Pointer())
+	case reflect.Array, reflect.Struct:
+	default:
+		if rv.CanInterface() {
+			return slog.AnyValue(rv.Interface())
+		}
+		return slog.StringValue(fmt.Sprint(rv))
+	}
+
+	if depth >= t.maxDepth {
+		return slog.StringValue(summary(rv))
+	}
+
+	var attrs []slog.Attr
+	switch rv.Kind() {
+	case reflect.Map:
+		keys := rv.MapKeys()
+		slices.SortFunc(keys, compareKeys)
+		for i, k := range keys {
+			if i == t.maxElems {
+				attrs = append(attrs, more(len(keys)-i))
+				break
+			}
+			attrs = append(attrs, slog.Attr{Key: keyString(k), Value: t.node(rv.MapIndex(k), depth+1)})
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if i == t.maxElems {
+				attrs = append(attrs, more(rv.Len()-i))
+				break
+			}
+			attrs = append(attrs, slog.Attr{Key: strconv.Itoa(i), Value: t.node(rv.Index(i), depth+1)})
+		}
+	case reflect.Struct:
+		attrs = t.fields(rv, depth, attrs)
+	}
+	if len(attrs) == 0 {
+		return slog.StringValue(summary(rv))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// fields appends the exported fields of rv keyed by their json names, embedded structs without
+// a json name are inlined as in encoding/json.
+func (t *valueTree) fields(rv reflect.Value, depth int, attrs []slog.Attr) []slog.Attr {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		fv := rv.Field(i)
+		if strings.Contains(opts, "omitempty") && fv.IsZero() {
+			continue
+		}
+		if name == "" && f.Anonymous {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if v := t.node(fv, depth); v.Kind() == slog.KindGroup {
+					attrs = append(attrs, v.Group()...)
+				}
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		attrs = append(attrs, slog.Attr{Key: name, Value: t.node(fv, depth+1)})
+	}
+	return attrs
+}
+
+func (t *valueTree) onPath(ptr uintptr) bool {
+	return slices.Contains(t.path, ptr)
+}
+
+// leaf reports whether x is rendered as a single value: errors, Stringers and LogValuers keep
+// the representation chosen by their type.
+func leaf(x any) bool {
+	switch x.(type) {
+	case error, fmt.Stringer, slog.LogValuer:
+		return true
+	}
+	return false
+}
+
+func bytesValue(b []byte) slog.Value {
+	if utf8.Valid(b) {
+		return slog.StringValue(string(b))
+	}
+	return slog.StringValue(fmt.Sprintf("<%d bytes>", len(b)))
+}
+
+func more(n int) slog.Attr {
+	return slog.String("…", strconv.Itoa(n)+" more")
+}
+
+// summary describes a container that is not expanded.
+func summary(rv reflect.Value) string {
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return rv.Type().String() + "(len=" + strconv.Itoa(rv.Len()) + ")"
+	default:
+		return rv.Type().String() + "{…}"
+	}
+}
+
+func keyString(k reflect.Value) string {
+	for k.Kind() == reflect.Interface && !k.IsNil() {
+		k = k.Elem()
+	}
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	if k.CanInterface() {
+		return fmt.Sprint(k.Interface())
+	}
+	return k.String()
+}
+
+// compareKeys orders numbers by value and other keys by their string form.
+func compareKeys(a, b reflect.Value) int {
+	for a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	for b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+	if a.Kind() == b.Kind() {
+		switch {
+		case a.CanInt():
+			return cmp.Compare(a.Int(), b.Int())
+		case a.CanUint():
+			return cmp.Compare(a.Uint(), b.Uint())
+		case a.CanFloat():
+			return cmp.Compare(a.Float(), b.Float())
+		}
+	}
+	return strings.Compare(keyString(a), keyString(b))
+}
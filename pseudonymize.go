@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"strings"
+)
+
+const pseudonymPrefix = "pii_"
+
+// IPMode says how IP address values are pseudonymized.
+type IPMode int
+
+const (
+	// IPHash replaces addresses with a token like any other value.
+	IPHash IPMode = iota
+	// IPTruncate keeps the address format and zeroes the host part:
+	// the last octet of IPv4 and everything after /48 for IPv6.
+	IPTruncate
+)
+
+type PseudonymizeOptions struct {
+	// Keys lists the attributes to pseudonymize, with the same path syntax as RedactRule.Key.
+	Keys []string
+	// Secrets maps a key ID to an HMAC key, new tokens are derived with Secrets[KeyID].
+	// Old keys can be kept around so tokens from older logs can still be re-derived.
+	Secrets map[string][]byte
+	KeyID   string
+	IPMode  IPMode
+}
+
+// Pseudonymizer replaces configured attributes with stable HMAC-SHA256 tokens
+// of the form pii_<kid>_<hex>, so the same customer can be correlated across records
+// without storing the raw value. Its ReplaceAttr method can be used in HandlerOptions directly.
+type Pseudonymizer struct {
+	keys    [][]string
+	secrets map[string][]byte
+	kid     string
+	ipMode  IPMode
+}
+
+var ErrUnknownKeyID = errors.New("unknown key id")
+
+func NewPseudonymizer(opts *PseudonymizeOptions) (*Pseudonymizer, error) {
+	if opts == nil || len(opts.Secrets[opts.KeyID]) == 0 {
+		return nil, ErrUnknownKeyID
+	}
+
+	p := &Pseudonymizer{
+		secrets: opts.Secrets,
+		kid:     opts.KeyID,
+		ipMode:  opts.IPMode,
+	}
+	for _, k := range opts.Keys {
+		p.keys = append(p.keys, splitKeyPattern(k))
+	}
+	return p, nil
+}
+
+// NewPseudonymizeHandler returns a handler that pseudonymizes attributes before passing records to next.
+func NewPseudonymizeHandler(next Handler, opts *PseudonymizeOptions) (Handler, error) {
+	p, err := NewPseudonymizer(opts)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplaceAttrHandler(next, p.ReplaceAttr), nil
+}
+
+func (p *Pseudonymizer) ReplaceAttr(groups []string, a Attr) Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+
+	path := append(groups[:len(groups):len(groups)], a.Key)
+	for _, k := range p.keys {
+		if matchKeyPattern(k, path) {
+			return slog.String(a.Key, p.pseudonymize(a.Value.Resolve().String()))
+		}
+	}
+	return a
+}
+
+// Token derives the token for a raw value with the current key, for searching logs.
+func (p *Pseudonymizer) Token(raw string) string {
+	t, _ := p.TokenWithKey(p.kid, raw)
+	return t
+}
+
+// TokenWithKey derives the token for a raw value with a rotated-out key.
+func (p *Pseudonymizer) TokenWithKey(kid, raw string) (string, error) {
+	secret, ok := p.secrets[kid]
+	if !ok {
+		return "", ErrUnknownKeyID
+	}
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(normalizePseudonymInput(raw)))
+	return pseudonymPrefix + kid + "_" + hex.EncodeToString(m.Sum(nil)[:16]), nil
+}
+
+func (p *Pseudonymizer) pseudonymize(s string) string {
+	if p.ipMode == IPTruncate {
+		if addr, err := netip.ParseAddr(s); err == nil {
+			return truncateIP(addr).String()
+		}
+	}
+	return p.Token(s)
+}
+
+// normalizePseudonymInput makes equal emails and addresses produce equal tokens regardless of case and spacing.
+func normalizePseudonymInput(s string) string {
+	s = strings.TrimSpace(s)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap().String()
+	}
+	if strings.Contains(s, "@") {
+		return strings.ToLower(s)
+	}
+	return s
+}
+
+func truncateIP(addr netip.Addr) netip.Addr {
+	addr = addr.Unmap()
+	bits := 24
+	if addr.Is6() {
+		bits = 48
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return addr
+	}
+	return prefix.Addr()
+}
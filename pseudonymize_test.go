@@ -0,0 +1,105 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"regexp"
+	"testing"
+)
+
+var tokenPattern = regexp.MustCompile(`^pii_(k[0-9])_[0-9a-f]{32}$`)
+
+func testPseudonymizer(t *testing.T, kid string, mode IPMode) *Pseudonymizer {
+	t.Helper()
+	p, err := NewPseudonymizer(&PseudonymizeOptions{
+		Keys:    []string{"email", "client_ip", "user.id"},
+		Secrets: map[string][]byte{"k1": []byte("secret-1"), "k2": []byte("secret-2")},
+		KeyID:   kid,
+		IPMode:  mode,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPseudonymizeTokens(t *testing.T) {
+	p1 := testPseudonymizer(t, "k1", IPHash)
+	p2 := testPseudonymizer(t, "k2", IPHash)
+
+	tok := p1.Token("bob@example.com")
+	if m := tokenPattern.FindStringSubmatch(tok); m == nil || m[1] != "k1" {
+		t.Fatalf("token = %q", tok)
+	}
+	for _, same := range []string{"bob@example.com", " Bob@Example.COM ", "bob@example.com\n"} {
+		if got := p1.Token(same); got != tok {
+			t.Errorf("Token(%q) = %q, want %q", same, got, tok)
+		}
+	}
+	if p1.Token("alice@example.com") == tok {
+		t.Fatal("different values share a token")
+	}
+	if p1.Token("10.0.0.1") != p1.Token("::ffff:10.0.0.1") {
+		t.Fatal("IPv4-mapped address has its own token")
+	}
+
+	// tokens depend on the key, rotated keys still derive the old tokens
+	tok2 := p2.Token("bob@example.com")
+	if m := tokenPattern.FindStringSubmatch(tok2); m == nil || m[1] != "k2" || tok2[8:] == tok[8:] {
+		t.Fatalf("token with k2 = %q, k1 = %q", tok2, tok)
+	}
+	if old, err := p2.TokenWithKey("k1", "bob@example.com"); err != nil || old != tok {
+		t.Fatalf("TokenWithKey(k1) = %q, %v, want %q", old, err, tok)
+	}
+	if _, err := p2.TokenWithKey("k9", "bob"); !errors.Is(err, ErrUnknownKeyID) {
+		t.Fatalf("TokenWithKey(k9) error = %v", err)
+	}
+	if _, err := NewPseudonymizer(&PseudonymizeOptions{Secrets: map[string][]byte{"k1": []byte("s")}, KeyID: "k2"}); !errors.Is(err, ErrUnknownKeyID) {
+		t.Fatalf("NewPseudonymizer with a missing key error = %v", err)
+	}
+}
+
+func TestPseudonymizeIPTruncate(t *testing.T) {
+	p := testPseudonymizer(t, "k1", IPTruncate)
+	tests := []struct {
+		in, want string
+	}{
+		{"203.0.113.77", "203.0.113.0"},
+		{"::ffff:203.0.113.77", "203.0.113.0"},
+		{"2001:db8:abcd:12:1:2:3:4", "2001:db8:abcd::"},
+		{"not-an-ip", p.Token("not-an-ip")},
+	}
+	for _, tt := range tests {
+		a := p.ReplaceAttr(nil, slog.String("client_ip", tt.in))
+		if got := a.Value.String(); got != tt.want {
+			t.Errorf("client_ip %q = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if got := testPseudonymizer(t, "k1", IPHash).ReplaceAttr(nil, slog.String("client_ip", "203.0.113.77")).Value.String(); !tokenPattern.MatchString(got) {
+		t.Errorf("IPHash client_ip = %q", got)
+	}
+}
+
+func TestPseudonymizeHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := NewPseudonymizeHandler(slog.NewJSONHandler(&buf, nil), &PseudonymizeOptions{
+		Keys:    []string{"email", "user.id"},
+		Secrets: map[string][]byte{"k1": []byte("secret-1")},
+		KeyID:   "k1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := testPseudonymizer(t, "k1", IPHash)
+
+	slog.New(h).Info("login", "email", "bob@example.com", slog.Group("user", "id", 42, "plan", "pro"), "id", 42)
+	m := decodeJSONLine(t, &buf)
+	if m["email"] != p.Token("bob@example.com") || m["id"] != 42.0 {
+		t.Fatalf("record = %v", m)
+	}
+	if user := m["user"].(map[string]any); user["id"] != p.Token("42") || user["plan"] != "pro" {
+		t.Fatalf("user = %v", user)
+	}
+}
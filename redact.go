@@ -0,0 +1,406 @@
+package logger
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultRedactMask = "[REDACTED]"
+	// redactMaxDepth limits how deep maps and slices in Any values are walked.
+	redactMaxDepth = 8
+)
+
+// RedactAction says what happens to a matched value.
+type RedactAction int
+
+const (
+	// RedactMask replaces the value with RedactOptions.Mask.
+	RedactMask RedactAction = iota
+	// RedactPartial keeps the last four characters: ****1234.
+	RedactPartial
+	// RedactHash replaces the value with a short (keyed) SHA-256 digest, equal values stay correlatable.
+	RedactHash
+	// RedactDrop removes the whole attribute.
+	RedactDrop
+)
+
+// RedactRule matches an attribute by its key path or by its value.
+//
+// Key is a case-insensitive dot-separated path such as "http.headers.authorization".
+// A "*" segment matches one group and "**" matches any number of groups. Patterns are not anchored,
+// leading wildcards are ignored, so "password" and "*.password" both hit the key at any depth,
+// including the top level. A rule that matches a group applies to everything inside it, keys of
+// maps in Any values are matched as groups too.
+//
+// Value is matched against string values, errors, Stringers and the strings inside maps and slices,
+// only the matched parts are replaced unless the action is RedactDrop.
+// Validate, if set, filters the Value matches (for example a Luhn check for card numbers).
+type RedactRule struct {
+	Key      string
+	Value    *regexp.Regexp
+	Validate func(match string) bool
+	Action   RedactAction
+}
+
+type RedactOptions struct {
+	Rules []RedactRule
+	// Mask is the RedactMask replacement, "[REDACTED]" by default.
+	Mask string
+	// HashKey turns RedactHash into HMAC-SHA256, so digests can't be brute-forced without it.
+	HashKey []byte
+}
+
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	bearerPattern = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+	jwtPattern    = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	cardPattern   = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+)
+
+var (
+	RedactEmails       = RedactRule{Value: emailPattern, Action: RedactMask}
+	RedactBearerTokens = RedactRule{Value: bearerPattern, Action: RedactMask}
+	RedactJWTs         = RedactRule{Value: jwtPattern, Action: RedactMask}
+	RedactCardNumbers  = RedactRule{Value: cardPattern, Validate: luhnValid, Action: RedactPartial}
+)
+
+// DefaultRedactRules covers common secret keys, emails, bearer tokens, JWTs and card numbers.
+func DefaultRedactRules() []RedactRule {
+	rules := []RedactRule{}
+	for _, k := range []string{
+		"password", "passwd", "secret", "token", "access_token", "refresh_token",
+		"api_key", "apikey", "authorization", "cookie", "set-cookie", "private_key",
+	} {
+		rules = append(rules, RedactRule{Key: k, Action: RedactMask})
+	}
+	return append(rules, RedactBearerTokens, RedactJWTs, RedactEmails, RedactCardNumbers)
+}
+
+// Redactor masks sensitive attributes, its ReplaceAttr method can be used in HandlerOptions directly.
+type Redactor struct {
+	keyRules   []keyRule
+	valueRules []RedactRule
+	mask       string
+	hashKey    []byte
+}
+
+type keyRule struct {
+	pattern []string
+	action  RedactAction
+}
+
+func NewRedactor(opts *RedactOptions) *Redactor {
+	if opts == nil {
+		opts = &RedactOptions{Rules: DefaultRedactRules()}
+	}
+
+	r := &Redactor{mask: opts.Mask, hashKey: opts.HashKey}
+	if r.mask == "" {
+		r.mask = defaultRedactMask
+	}
+
+	for _, rule := range opts.Rules {
+		if rule.Key != "" {
+			r.keyRules = append(r.keyRules, keyRule{pattern: splitKeyPattern(rule.Key), action: rule.Action})
+		}
+		if rule.Value != nil {
+			r.valueRules = append(r.valueRules, rule)
+		}
+	}
+	return r
+}
+
+type redactHandler struct {
+	next     Handler
+	redactor *Redactor
+}
+
+// NewRedactHandler returns a handler that redacts attributes and applies the value rules to the
+// message before passing records to next. If opts is nil, DefaultRedactRules are used.
+func NewRedactHandler(next Handler, opts *RedactOptions) Handler {
+	r := NewRedactor(opts)
+	return &redactHandler{next: NewReplaceAttrHandler(next, r.ReplaceAttr), redactor: r}
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	// a message can't be dropped, a RedactDrop match masks all of it
+	if msg, drop := h.redactor.redactString(r.Message); drop {
+		r.Message = h.redactor.mask
+	} else {
+		r.Message = msg
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &redactHandler{next: h.next.WithAttrs(attrs), redactor: h.redactor}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
+
+func (r *Redactor) ReplaceAttr(groups []string, a Attr) Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+
+	path := append(groups[:len(groups):len(groups)], a.Key)
+	if action, ok := r.matchKey(path); ok {
+		if action == RedactDrop {
+			return slog.Attr{}
+		}
+		return slog.String(a.Key, r.apply(action, a.Value.Resolve().String()))
+	}
+
+	return r.redactValue(path, a)
+}
+
+func (r *Redactor) matchKey(path []string) (RedactAction, bool) {
+	for _, kr := range r.keyRules {
+		if matchKeyPattern(kr.pattern, path) {
+			return kr.action, true
+		}
+	}
+	return 0, false
+}
+
+// Redact applies the value rules to s.
+func (r *Redactor) Redact(s string) string {
+	s, _ = r.redactString(s)
+	return s
+}
+
+func (r *Redactor) redactValue(path []string, a Attr) Attr {
+	v := a.Value.Resolve()
+
+	var s string
+	switch v.Kind() {
+	case slog.KindString:
+		s = v.String()
+	case slog.KindAny:
+		x, changed, drop := r.redactAny(path, v.Any(), 0)
+		if drop {
+			return slog.Attr{}
+		}
+		if !changed {
+			return a
+		}
+		return slog.Any(a.Key, x)
+	default:
+		return a
+	}
+
+	redacted, drop := r.redactString(s)
+	if drop {
+		return slog.Attr{}
+	}
+	if redacted == s {
+		return a
+	}
+	return slog.String(a.Key, redacted)
+}
+
+// redactAny redacts strings, errors and Stringers and walks maps and slices, matching map keys
+// against the key rules. Changed values become strings, maps become map[string]any and slices []any,
+// so the original is never modified. Untouched values are returned as they are.
+func (r *Redactor) redactAny(path []string, v any, depth int) (x any, changed, drop bool) {
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case error:
+		s = t.Error()
+	case fmt.Stringer:
+		s = t.String()
+	default:
+		return r.redactContainer(path, v, depth)
+	}
+
+	redacted, drop := r.redactString(s)
+	if drop {
+		return nil, true, true
+	}
+	if redacted == s {
+		return v, false, false
+	}
+	return redacted, true, false
+}
+
+func (r *Redactor) redactContainer(path []string, v any, depth int) (any, bool, bool) {
+	rv := reflect.ValueOf(v)
+	if depth >= redactMaxDepth {
+		return v, false, false
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		out := make(map[string]any, rv.Len())
+		changed := false
+		for it := rv.MapRange(); it.Next(); {
+			k := fmt.Sprint(it.Key().Interface())
+			kpath := append(path[:len(path):len(path)], k)
+			if action, ok := r.matchKey(kpath); ok {
+				changed = true
+				if action != RedactDrop {
+					out[k] = r.apply(action, fmt.Sprint(it.Value().Interface()))
+				}
+				continue
+			}
+			x, c, drop := r.redactAny(kpath, it.Value().Interface(), depth+1)
+			changed = changed || c
+			if !drop {
+				out[k] = x
+			}
+		}
+		if !changed {
+			return v, false, false
+		}
+		return out, true, false
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v, false, false
+		}
+		out := make([]any, 0, rv.Len())
+		changed := false
+		for i := range rv.Len() {
+			x, c, drop := r.redactAny(path, rv.Index(i).Interface(), depth+1)
+			changed = changed || c
+			if !drop {
+				out = append(out, x)
+			}
+		}
+		if !changed {
+			return v, false, false
+		}
+		return out, true, false
+	}
+	return v, false, false
+}
+
+func (r *Redactor) redactString(s string) (string, bool) {
+	for _, rule := range r.valueRules {
+		drop := false
+		s = rule.Value.ReplaceAllStringFunc(s, func(m string) string {
+			if rule.Validate != nil && !rule.Validate(m) {
+				return m
+			}
+			if rule.Action == RedactDrop {
+				drop = true
+				return m
+			}
+			return r.apply(rule.Action, m)
+		})
+		if drop {
+			return "", true
+		}
+	}
+	return s, false
+}
+
+func (r *Redactor) apply(action RedactAction, s string) string {
+	switch action {
+	case RedactPartial:
+		rs := []rune(s)
+		if len(rs) <= 4 {
+			return "****"
+		}
+		return "****" + string(rs[len(rs)-4:])
+	case RedactHash:
+		return "sha256:" + r.hash(s)
+	default:
+		return r.mask
+	}
+}
+
+func (r *Redactor) hash(s string) string {
+	var sum []byte
+	if len(r.hashKey) > 0 {
+		m := hmac.New(sha256.New, r.hashKey)
+		m.Write([]byte(s))
+		sum = m.Sum(nil)
+	} else {
+		h := sha256.Sum256([]byte(s))
+		sum = h[:]
+	}
+	return hex.EncodeToString(sum[:8])
+}
+
+/*--------------------------------KEY PATTERNS-----------------------------------------------*/
+
+// splitKeyPattern splits p into segments and drops leading wildcards, they make no difference
+// for an unanchored pattern except that they would require a parent group.
+func splitKeyPattern(p string) []string {
+	segs := strings.Split(strings.ToLower(p), ".")
+	for len(segs) > 1 && (segs[0] == "*" || segs[0] == "**") {
+		segs = segs[1:]
+	}
+	return segs
+}
+
+// matchKeyPattern reports whether pattern matches any contiguous part of path,
+// so rules hit keys at any depth and everything nested below a matched group.
+func matchKeyPattern(pattern, path []string) bool {
+	for i := range path {
+		for j := i + 1; j <= len(path); j++ {
+			if matchKeyPath(pattern, path[i:j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchKeyPath(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	switch pattern[0] {
+	case "**":
+		for i := 0; i <= len(path); i++ {
+			if matchKeyPath(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(path) > 0 && matchKeyPath(pattern[1:], path[1:])
+	default:
+		return len(path) > 0 && strings.EqualFold(pattern[0], path[0]) && matchKeyPath(pattern[1:], path[1:])
+	}
+}
+
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && n <= 19 && sum%10 == 0
+}
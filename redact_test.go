@@ -0,0 +1,132 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedactKeyRules(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"password", "password", true},
+		{"password", "user.password", true},
+		{"PASSWORD", "user.Password", true},
+		{"*.password", "password", true},
+		{"*.password", "user.password", true},
+		{"**.password", "a.b.password", true},
+		{"user.password", "password", false},
+		{"user.password", "req.user.password", true},
+		{"user.*.token", "user.oauth.token", true},
+		{"user.*.token", "user.token", false},
+		{"user.**.token", "user.token", true},
+		{"user.**.token", "user.a.b.token", true},
+		{"headers", "http.headers.cookie", true},
+		{"password", "password_hint", false},
+		{"*", "anything", true},
+	}
+	for _, tt := range tests {
+		got := matchKeyPattern(splitKeyPattern(tt.pattern), strings.Split(tt.path, "."))
+		if got != tt.want {
+			t.Errorf("pattern %q on %q = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRedactorReplaceAttr(t *testing.T) {
+	r := NewRedactor(&RedactOptions{
+		Rules: append(DefaultRedactRules(),
+			RedactRule{Key: "ssn", Action: RedactPartial},
+			RedactRule{Key: "debug", Action: RedactDrop},
+			RedactRule{Key: "*.user_id", Action: RedactHash},
+			RedactRule{Value: regexp.MustCompile(`internal-\d+`), Action: RedactDrop},
+		),
+	})
+	tests := []struct {
+		name   string
+		groups []string
+		attr   slog.Attr
+		want   string // slog.Value.String() of the result, "-" if dropped
+	}{
+		{"top-level key", nil, slog.String("password", "hunter2"), "[REDACTED]"},
+		{"nested key", []string{"req"}, slog.String("Authorization", "Basic x"), "[REDACTED]"},
+		{"group key applies inside", []string{"cookie"}, slog.String("sid", "abc"), "[REDACTED]"},
+		{"partial", nil, slog.String("ssn", "123-45-6789"), "****6789"},
+		{"drop key", nil, slog.Int("debug", 1), "-"},
+		{"hash", nil, slog.Int("user_id", 42), "sha256:" + r.hash("42")},
+		{"plain", nil, slog.String("name", "bob"), "bob"},
+		{"email", nil, slog.String("note", "mail bob@example.com now"), "mail [REDACTED] now"},
+		{"bearer", nil, slog.String("h", "Bearer abc.def.ghi"), "[REDACTED]"},
+		{"card", nil, slog.String("pan", "4111 1111 1111 1111"), "****1111"},
+		{"not luhn", nil, slog.String("pan", "4111 1111 1111 1112"), "4111 1111 1111 1112"},
+		{"error", nil, slog.Any("err", errors.New("no user bob@example.com")), "no user [REDACTED]"},
+		{"drop value", nil, slog.String("host", "internal-42"), "-"},
+		{"int untouched", nil, slog.Int("n", 7), "7"},
+	}
+	for _, tt := range tests {
+		a := r.ReplaceAttr(tt.groups, tt.attr)
+		got := "-"
+		if !a.Equal(slog.Attr{}) {
+			got = a.Value.String()
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRedactorWalksMapsAndSlices(t *testing.T) {
+	r := NewRedactor(nil)
+
+	user := map[string]any{
+		"email": "bob@example.com",
+		"name":  "bob",
+		"auth":  map[string]string{"token": "t0ps3cret", "kind": "oauth"},
+		"tags":  []string{"admin", "Bearer xyz"},
+	}
+	a := r.ReplaceAttr(nil, slog.Any("user", user))
+	m, ok := a.Value.Any().(map[string]any)
+	if !ok {
+		t.Fatalf("value = %#v, want a redacted map", a.Value.Any())
+	}
+	if m["email"] != "[REDACTED]" || m["name"] != "bob" {
+		t.Fatalf("map = %v", m)
+	}
+	if auth := m["auth"].(map[string]any); auth["token"] != "[REDACTED]" || auth["kind"] != "oauth" {
+		t.Fatalf("nested map = %v", auth)
+	}
+	if tags := m["tags"].([]any); len(tags) != 2 || tags[0] != "admin" || tags[1] != "[REDACTED]" {
+		t.Fatalf("slice = %v", tags)
+	}
+	if user["email"] != "bob@example.com" {
+		t.Fatal("original map modified")
+	}
+
+	clean := []int{1, 2}
+	if a := r.ReplaceAttr(nil, slog.Any("ids", clean)); a.Value.Any().([]int)[0] != 1 {
+		t.Fatalf("untouched slice replaced: %#v", a.Value.Any())
+	}
+}
+
+func TestRedactHandlerMessage(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(NewRedactHandler(slog.NewJSONHandler(&buf, nil), nil))
+
+	l.WithGroup("req").Info("login by bob@example.com with Bearer abc.def.ghi", "password", "hunter2")
+	m := decodeJSONLine(t, &buf)
+	if m["msg"] != "login by [REDACTED] with [REDACTED]" {
+		t.Fatalf("msg = %q", m["msg"])
+	}
+	if req := m["req"].(map[string]any); req["password"] != "[REDACTED]" {
+		t.Fatalf("group = %v", req)
+	}
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Fatal("secret leaked")
+	}
+}
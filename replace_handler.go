@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ReplaceAttrFunc has the signature of HandlerOptions.ReplaceAttr.
+type ReplaceAttrFunc func(groups []string, a Attr) Attr
+
+type replaceAttrHandler struct {
+	next    Handler
+	replace ReplaceAttrFunc
+	groups  []string
+}
+
+// NewReplaceAttrHandler returns a handler that applies replace to every attribute before passing
+// the record to next. Unlike HandlerOptions.ReplaceAttr it works in front of any handler,
+// including the pretty one. LogValuers are resolved and nested groups are walked, replace is called
+// for non-group attributes with the full group path, and an empty result drops the attribute.
+func NewReplaceAttrHandler(next Handler, replace ReplaceAttrFunc) Handler {
+	return &replaceAttrHandler{next: next, replace: replace}
+}
+
+func (h *replaceAttrHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *replaceAttrHandler) Handle(ctx context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if a = replaceAttr(h.replace, h.groups, a); !a.Equal(slog.Attr{}) {
+			nr.AddAttrs(a)
+		}
+		return true
+	})
+	return h.next.Handle(ctx, nr)
+}
+
+func (h *replaceAttrHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	replaced := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a = replaceAttr(h.replace, h.groups, a); !a.Equal(slog.Attr{}) {
+			replaced = append(replaced, a)
+		}
+	}
+	return &replaceAttrHandler{next: h.next.WithAttrs(replaced), replace: h.replace, groups: h.groups}
+}
+
+func (h *replaceAttrHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &replaceAttrHandler{
+		next:    h.next.WithGroup(name),
+		replace: h.replace,
+		groups:  append(h.groups[:len(h.groups):len(h.groups)], name),
+	}
+}
+
+func replaceAttr(replace ReplaceAttrFunc, groups []string, a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup {
+		return replace(groups, a)
+	}
+
+	if a.Key != "" {
+		groups = append(groups[:len(groups):len(groups)], a.Key)
+	}
+	children := a.Value.Group()
+	replaced := make([]slog.Attr, 0, len(children))
+	for _, ga := range children {
+		if ga = replaceAttr(replace, groups, ga); !ga.Equal(slog.Attr{}) {
+			replaced = append(replaced, ga)
+		}
+	}
+	if len(replaced) == 0 {
+		return slog.Attr{}
+	}
+	return slog.Attr{Key: a.Key, Value: slog.GroupValue(replaced...)}
+}
+
+// ChainReplaceAttr combines several ReplaceAttr functions, an empty result stops the chain.
+func ChainReplaceAttr(fns ...ReplaceAttrFunc) ReplaceAttrFunc {
+	return func(groups []string, a Attr) Attr {
+		for _, fn := range fns {
+			if fn == nil {
+				continue
+			}
+			if a = fn(groups, a); a.Equal(slog.Attr{}) {
+				return a
+			}
+		}
+		return a
+	}
+}
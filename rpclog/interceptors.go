@@ -0,0 +1,271 @@
+// Package rpclog provides gRPC interceptors that log calls through the logger package.
+// Server interceptors put a per-call logger into the context, so handlers keep using logger.ExtractLogger.
+package rpclog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/FurmanovVitaliy/logger"
+)
+
+const (
+	RequestIDMetadataKey   = "x-request-id"
+	TraceparentMetadataKey = "traceparent"
+)
+
+type Options struct {
+	// CodeLevel maps a status code to a log level, DefaultCodeLevel is used if nil.
+	CodeLevel func(codes.Code) logger.Level
+	// NoPropagation disables sending the request ID and traceparent in outgoing metadata.
+	NoPropagation bool
+}
+
+// DefaultCodeLevel logs client-caused codes at Info, codes that need attention at Warn and server faults at Error.
+func DefaultCodeLevel(code codes.Code) logger.Level {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Unauthenticated:
+		return logger.LevelInfo
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange:
+		return logger.LevelWarn
+	default:
+		return logger.LevelError
+	}
+}
+
+func options(opts *Options) Options {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.CodeLevel == nil {
+		o.CodeLevel = DefaultCodeLevel
+	}
+	return o
+}
+
+/*--------------------------------SERVER-----------------------------------------------------*/
+
+// UnaryServerInterceptor logs unary calls, if log is nil the logger from the call context is used.
+func UnaryServerInterceptor(log *logger.Logger, opts *Options) grpc.UnaryServerInterceptor {
+	o := options(opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx, callLog := serverContext(ctx, log, info.FullMethod)
+
+		resp, err := handler(ctx, req)
+
+		o.log(ctx, callLog, "grpc call", err, time.Since(start), peerAttr(ctx))
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor logs streaming calls with the number of sent and received messages.
+func StreamServerInterceptor(log *logger.Logger, opts *Options) grpc.StreamServerInterceptor {
+	o := options(opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, callLog := serverContext(ss.Context(), log, info.FullMethod)
+
+		ws := &serverStream{ServerStream: ss, ctx: ctx}
+		err := handler(srv, ws)
+
+		o.log(ctx, callLog, "grpc stream", err, time.Since(start), peerAttr(ctx),
+			slog.Int64("sent", ws.sent.Load()),
+			slog.Int64("received", ws.received.Load()),
+		)
+		return err
+	}
+}
+
+func serverContext(ctx context.Context, log *logger.Logger, method string) (context.Context, *logger.Logger) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	id := first(md, RequestIDMetadataKey)
+	if !logger.ValidRequestID(id) {
+		id = logger.NewRequestID()
+	}
+	ctx = logger.ContextWithRequestID(ctx, id)
+
+	if _, ok := logger.TraceFromContext(ctx); !ok {
+		if tc, err := logger.ParseTraceparent(first(md, TraceparentMetadataKey)); err == nil {
+			ctx = logger.ContextWithTraceparent(ctx, tc)
+		}
+	}
+
+	if log == nil {
+		log = logger.ExtractLogger(ctx)
+	}
+	callLog := log.With(slog.String("request_id", id), slog.String("grpc_method", method))
+	return logger.ContextWithLogger(ctx, callLog), callLog
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent.Add(1)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received.Add(1)
+	}
+	return err
+}
+
+/*--------------------------------CLIENT-----------------------------------------------------*/
+
+// UnaryClientInterceptor logs outgoing unary calls with the logger from the call context.
+func UnaryClientInterceptor(opts *Options) grpc.UnaryClientInterceptor {
+	o := options(opts)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		start := time.Now()
+		if !o.NoPropagation {
+			ctx = outgoingContext(ctx)
+		}
+
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+
+		log := logger.ExtractLogger(ctx).With(slog.String("grpc_method", method))
+		o.log(ctx, log, "grpc client call", err, time.Since(start), slog.String("target", cc.Target()))
+		return err
+	}
+}
+
+// StreamClientInterceptor logs outgoing streams once they finish.
+func StreamClientInterceptor(opts *Options) grpc.StreamClientInterceptor {
+	o := options(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		if !o.NoPropagation {
+			ctx = outgoingContext(ctx)
+		}
+		log := logger.ExtractLogger(ctx).With(slog.String("grpc_method", method))
+		target := slog.String("target", cc.Target())
+
+		cs, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			o.log(ctx, log, "grpc client stream", err, time.Since(start), target)
+			return cs, err
+		}
+
+		ws := &clientStream{ClientStream: cs, serverStreams: desc.ServerStreams}
+		ws.done = func(err error) {
+			o.log(ctx, log, "grpc client stream", err, time.Since(start), target,
+				slog.Int64("sent", ws.sent.Load()),
+				slog.Int64("received", ws.received.Load()),
+			)
+		}
+		return ws, nil
+	}
+}
+
+func outgoingContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+
+	if id := logger.RequestIDFromContext(ctx); id != "" && len(md.Get(RequestIDMetadataKey)) == 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, RequestIDMetadataKey, id)
+	}
+	if tc, ok := logger.TraceFromContext(ctx); ok && len(md.Get(TraceparentMetadataKey)) == 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, TraceparentMetadataKey, tc.Child().String())
+	}
+	return ctx
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	// serverStreams is false for client-streaming calls, they end with the single response.
+	serverStreams bool
+	sent          atomic.Int64
+	received      atomic.Int64
+	once          sync.Once
+	done          func(error)
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.sent.Add(1)
+	} else if err != io.EOF {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.received.Add(1)
+		if !s.serverStreams {
+			s.finish(nil)
+		}
+	case err == io.EOF:
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() { s.done(err) })
+}
+
+/*--------------------------------UTILS------------------------------------------------------*/
+
+func (o Options) log(ctx context.Context, log *logger.Logger, msg string, err error, d time.Duration, attrs ...slog.Attr) {
+	code := status.Code(err)
+	level := o.CodeLevel(code)
+	if !log.Enabled(ctx, level) {
+		return
+	}
+
+	attrs = append(attrs,
+		slog.String("grpc_code", code.String()),
+		slog.Duration("duration", d),
+	)
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+	log.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func peerAttr(ctx context.Context) slog.Attr {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return slog.String("peer", p.Addr.String())
+	}
+	return slog.String("peer", "")
+}
+
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,263 @@
+package rpclog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/FurmanovVitaliy/logger"
+)
+
+// logBuffer collects JSON log lines written from the server and client goroutines.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records returns the decoded lines with the message msg.
+func (b *logBuffer) records(t *testing.T, msg string) []map[string]any {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if m["msg"] == msg {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+var testService = grpc.ServiceDesc{
+	ServiceName: "rpclog.Test",
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Echo",
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			in := new(wrapperspb.StringValue)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/rpclog.Test/Echo"}
+			return interceptor(ctx, in, info, func(ctx context.Context, req any) (any, error) {
+				return req, nil
+			})
+		},
+	}},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Count",
+			ClientStreams: true,
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				var n int64
+				for {
+					err := stream.RecvMsg(new(wrapperspb.StringValue))
+					if err == io.EOF {
+						return stream.SendMsg(wrapperspb.Int64(n))
+					}
+					if err != nil {
+						return err
+					}
+					n++
+				}
+			},
+		},
+		{
+			StreamName:    "Repeat",
+			ServerStreams: true,
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				in := new(wrapperspb.Int64Value)
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				for i := int64(0); i < in.Value; i++ {
+					if err := stream.SendMsg(wrapperspb.Int64(i)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	},
+}
+
+// serve starts the test service on an in-memory listener with the server interceptors logging to
+// server and returns a client with the client interceptors. Stopping the server waits for its logs.
+func serve(t *testing.T, server *logBuffer) (*grpc.ClientConn, func()) {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	log := logger.New(slog.NewJSONHandler(server, nil))
+
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(log, nil)),
+		grpc.StreamInterceptor(StreamServerInterceptor(log, nil)),
+	)
+	s.RegisterService(&testService, struct{}{})
+	go s.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(nil)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(nil)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, func() {
+		conn.Close()
+		s.GracefulStop()
+	}
+}
+
+func clientContext(client *logBuffer) context.Context {
+	ctx := logger.ContextWithLogger(context.Background(), logger.New(slog.NewJSONHandler(client, nil)))
+	return logger.ContextWithRequestID(ctx, "req-1")
+}
+
+func TestUnaryPropagatesRequestID(t *testing.T) {
+	var server, client logBuffer
+	conn, stop := serve(t, &server)
+
+	out := new(wrapperspb.StringValue)
+	if err := conn.Invoke(clientContext(&client), "/rpclog.Test/Echo", wrapperspb.String("hi"), out); err != nil {
+		t.Fatal(err)
+	}
+	stop()
+
+	calls := server.records(t, "grpc call")
+	if len(calls) != 1 || calls[0]["request_id"] != "req-1" || calls[0]["grpc_code"] != "OK" {
+		t.Fatalf("server records = %v", calls)
+	}
+	if calls := client.records(t, "grpc client call"); len(calls) != 1 {
+		t.Fatalf("client records = %v", calls)
+	}
+}
+
+func TestClientStreamLoggedAfterResponse(t *testing.T) {
+	var server, client logBuffer
+	conn, stop := serve(t, &server)
+	defer stop()
+
+	desc := &testService.Streams[0]
+	cs, err := conn.NewStream(clientContext(&client), desc, "/rpclog.Test/Count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"a", "b", "c"} {
+		if err := cs.SendMsg(wrapperspb.String(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cs.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	out := new(wrapperspb.Int64Value)
+	if err := cs.RecvMsg(out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != 3 {
+		t.Fatalf("count = %d, want 3", out.Value)
+	}
+
+	// CloseAndRecv does not read io.EOF, the record must be written after the response
+	streams := client.records(t, "grpc client stream")
+	if len(streams) != 1 {
+		t.Fatalf("client records = %v", streams)
+	}
+	if streams[0]["sent"] != 3.0 || streams[0]["received"] != 1.0 || streams[0]["grpc_code"] != "OK" {
+		t.Fatalf("client record = %v", streams[0])
+	}
+
+	stop()
+	streams = server.records(t, "grpc stream")
+	if len(streams) != 1 || streams[0]["received"] != 3.0 || streams[0]["sent"] != 1.0 {
+		t.Fatalf("server records = %v", streams)
+	}
+}
+
+func TestServerStreamLoggedAtEOF(t *testing.T) {
+	var server, client logBuffer
+	conn, stop := serve(t, &server)
+	defer stop()
+
+	desc := &testService.Streams[1]
+	cs, err := conn.NewStream(clientContext(&client), desc, "/rpclog.Test/Repeat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.SendMsg(wrapperspb.Int64(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; ; i++ {
+		err := cs.RecvMsg(new(wrapperspb.Int64Value))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && len(client.records(t, "grpc client stream")) != 0 {
+			t.Fatal("server stream logged before io.EOF")
+		}
+	}
+
+	streams := client.records(t, "grpc client stream")
+	if len(streams) != 1 || streams[0]["sent"] != 1.0 || streams[0]["received"] != 2.0 {
+		t.Fatalf("client records = %v", streams)
+	}
+}
+
+func TestInvalidRequestIDReplaced(t *testing.T) {
+	var server, client logBuffer
+	conn, stop := serve(t, &server)
+
+	// gRPC itself rejects non-ASCII values on the client side
+	for _, id := range []string{"bad id", strings.Repeat("a", 129)} {
+		ctx := logger.ContextWithRequestID(clientContext(&client), id)
+		if err := conn.Invoke(ctx, "/rpclog.Test/Echo", wrapperspb.String("hi"), new(wrapperspb.StringValue)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stop()
+
+	calls := server.records(t, "grpc call")
+	if len(calls) != 2 {
+		t.Fatalf("server records = %v", calls)
+	}
+	for _, c := range calls {
+		if id, _ := c["request_id"].(string); len(id) != 32 || !logger.ValidRequestID(id) {
+			t.Fatalf("request_id = %q, want a new ID", c["request_id"])
+		}
+	}
+}
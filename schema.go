@@ -0,0 +1,349 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const ecsVersion = "8.11.0"
+
+// Schema is a field naming convention the normalizer rewrites records to.
+type Schema int
+
+const (
+	// SchemaECS is Elastic Common Schema: @timestamp, log.level, message, error.message…
+	SchemaECS Schema = iota
+	// SchemaOTel is OpenTelemetry semantic conventions: body, severity_text, exception.message…
+	SchemaOTel
+)
+
+var ecsMapping = map[string]string{
+	"err":          "error.message",
+	"error":        "error.message",
+	"errmsg":       "error.message",
+	"errormessage": "error.message",
+	"errortype":    "error.type",
+	"stack":        "error.stack_trace",
+	"stacktrace":   "error.stack_trace",
+	"userid":       "user.id",
+	"uid":          "user.id",
+	"username":     "user.name",
+	"useremail":    "user.email",
+	"requestid":    "http.request.id",
+	"traceid":      "trace.id",
+	"spanid":       "span.id",
+	"method":       "http.request.method",
+	"httpmethod":   "http.request.method",
+	"status":       "http.response.status_code",
+	"statuscode":   "http.response.status_code",
+	"url":          "url.full",
+	"path":         "url.path",
+	"ip":           "client.ip",
+	"clientip":     "client.ip",
+	"remoteip":     "client.ip",
+	"useragent":    "user_agent.original",
+	"duration":     "event.duration",
+	"latency":      "event.duration",
+	"service":      "service.name",
+	"servicename":  "service.name",
+	"host":         "host.name",
+	"hostname":     "host.name",
+}
+
+var otelMapping = map[string]string{
+	"err":          "exception.message",
+	"error":        "exception.message",
+	"errmsg":       "exception.message",
+	"errormessage": "exception.message",
+	"errortype":    "exception.type",
+	"stack":        "exception.stacktrace",
+	"stacktrace":   "exception.stacktrace",
+	"userid":       "enduser.id",
+	"uid":          "enduser.id",
+	"traceid":      "trace_id",
+	"spanid":       "span_id",
+	"method":       "http.request.method",
+	"httpmethod":   "http.request.method",
+	"status":       "http.response.status_code",
+	"statuscode":   "http.response.status_code",
+	"url":          "url.full",
+	"path":         "url.path",
+	"ip":           "client.address",
+	"clientip":     "client.address",
+	"remoteip":     "client.address",
+	"useragent":    "user_agent.original",
+	"service":      "service.name",
+	"servicename":  "service.name",
+	"host":         "host.name",
+	"hostname":     "host.name",
+}
+
+type NormalizeOptions struct {
+	Schema Schema
+	// Mapping adds or overrides key mappings. Source keys are matched ignoring case, "_", "-" and ".",
+	// so "user_id", "userId" and "user.id" are the same key.
+	Mapping map[string]string
+	// Nest turns dotted target keys into groups: error.message becomes {"error":{"message":…}}.
+	Nest bool
+}
+
+type normalizeHandler struct {
+	next     Handler
+	schema   Schema
+	mapping  map[string]string
+	nest     bool
+	inGroups bool
+}
+
+// NewNormalizeHandler returns a handler that renames record attributes to the field names of a schema
+// before passing the record to next. Only keys at the root of the record are renamed,
+// attributes added after WithGroup keep their keys. Built-in keys (time, level, msg, source)
+// are produced by the final handler, use Schema.ReplaceAttr or NewSchemaHandler for them.
+func NewNormalizeHandler(next Handler, opts *NormalizeOptions) Handler {
+	if opts == nil {
+		opts = &NormalizeOptions{}
+	}
+
+	h := &normalizeHandler{next: next, schema: opts.Schema, nest: opts.Nest, mapping: make(map[string]string)}
+
+	base := ecsMapping
+	if opts.Schema == SchemaOTel {
+		base = otelMapping
+	}
+	for k, v := range base {
+		h.mapping[k] = v
+	}
+	for k, v := range opts.Mapping {
+		h.mapping[normalizeKey(k)] = v
+	}
+	return h
+}
+
+// NewSchemaHandler is a JSON handler that writes records in the given schema, built-in keys included.
+func NewSchemaHandler(w io.Writer, schema Schema, opts *HandlerOptions) Handler {
+	o := HandlerOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	o.ReplaceAttr = ChainReplaceAttr(o.ReplaceAttr, schema.ReplaceAttr)
+
+	var json Handler = slog.NewJSONHandler(w, &o)
+	if schema == SchemaECS {
+		json = json.WithAttrs([]slog.Attr{slog.String("ecs.version", ecsVersion)})
+	}
+	return NewNormalizeHandler(json, &NormalizeOptions{Schema: schema, Nest: true})
+}
+
+func (h *normalizeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *normalizeHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.inGroups {
+		return h.next.Handle(ctx, r)
+	}
+
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(h.normalize(attrs)...)
+	return h.next.Handle(ctx, nr)
+}
+
+func (h *normalizeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	if !h.inGroups {
+		attrs = h.normalize(attrs)
+	}
+	h2.next = h.next.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *normalizeHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.next = h.next.WithGroup(name)
+	h2.inGroups = true
+	return &h2
+}
+
+func (h *normalizeHandler) normalize(attrs []slog.Attr) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Key == "" {
+			out = append(out, a)
+			continue
+		}
+
+		target, ok := h.mapping[normalizeKey(a.Key)]
+		if !ok {
+			out = append(out, a)
+			continue
+		}
+
+		if err, isErr := a.Value.Any().(error); isErr && a.Value.Kind() == slog.KindAny {
+			out = append(out, slog.String(target, err.Error()))
+			if typeKey := h.errorTypeKey(target); typeKey != "" {
+				out = append(out, slog.String(typeKey, fmt.Sprintf("%T", err)))
+			}
+			continue
+		}
+		if a.Value.Kind() == slog.KindDuration && h.schema == SchemaECS {
+			// event.duration is in nanoseconds
+			a.Value = slog.Int64Value(int64(a.Value.Duration()))
+		}
+		out = append(out, slog.Attr{Key: target, Value: a.Value})
+	}
+
+	if h.nest {
+		out = nestDottedKeys(out)
+	}
+	return out
+}
+
+func (h *normalizeHandler) errorTypeKey(target string) string {
+	switch target {
+	case "error.message":
+		return "error.type"
+	case "exception.message":
+		return "exception.type"
+	}
+	return ""
+}
+
+// ReplaceAttr renames the built-in keys written by the JSON and text handlers.
+func (s Schema) ReplaceAttr(groups []string, a Attr) Attr {
+	if len(groups) > 0 {
+		return a
+	}
+
+	switch a.Key {
+	case slog.TimeKey:
+		if s == SchemaOTel {
+			a.Key = "timestamp"
+		} else {
+			a.Key = "@timestamp"
+		}
+	case slog.LevelKey:
+		l, ok := a.Value.Any().(slog.Level)
+		if !ok {
+			return a
+		}
+		if s == SchemaOTel {
+			return slog.Group("",
+				slog.String("severity_text", l.String()),
+				slog.Int("severity_number", min(max(int(l)+9, 1), 24)),
+			)
+		}
+		return slog.String("log.level", strings.ToLower(l.String()))
+	case slog.MessageKey:
+		if s == SchemaOTel {
+			a.Key = "body"
+		} else {
+			a.Key = "message"
+		}
+	case slog.SourceKey:
+		src, ok := a.Value.Any().(*slog.Source)
+		if !ok {
+			return a
+		}
+		if s == SchemaOTel {
+			return slog.Group("",
+				slog.String("code.filepath", src.File),
+				slog.Int("code.lineno", src.Line),
+				slog.String("code.function", src.Function),
+			)
+		}
+		return slog.Group("log.origin",
+			slog.Group("file", slog.String("name", src.File), slog.Int("line", src.Line)),
+			slog.String("function", src.Function),
+		)
+	}
+	return a
+}
+
+// normalizeKey folds the spellings user_id, userId, user-id and user.id into one.
+func normalizeKey(k string) string {
+	var sb strings.Builder
+	sb.Grow(len(k))
+	for _, r := range strings.ToLower(k) {
+		if r == '_' || r == '-' || r == '.' {
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+type keyNode struct {
+	key      string
+	value    slog.Value
+	leaf     bool
+	children []*keyNode
+}
+
+// nestDottedKeys turns dotted keys into nested groups and merges groups that share a prefix,
+// keeping the order of first appearance.
+func nestDottedKeys(attrs []slog.Attr) []slog.Attr {
+	root := &keyNode{}
+	for _, a := range attrs {
+		if a.Key == "" {
+			root.children = append(root.children, &keyNode{value: a.Value, leaf: true})
+			continue
+		}
+		insertKeyNode(root, strings.Split(a.Key, "."), a.Value)
+	}
+	return keyNodeAttrs(root)
+}
+
+func insertKeyNode(n *keyNode, path []string, v slog.Value) {
+	if len(path) == 1 && v.Kind() != slog.KindGroup {
+		n.children = append(n.children, &keyNode{key: path[0], value: v, leaf: true})
+		return
+	}
+
+	var child *keyNode
+	for _, c := range n.children {
+		if c.key == path[0] && !c.leaf {
+			child = c
+			break
+		}
+	}
+	if child == nil {
+		child = &keyNode{key: path[0]}
+		n.children = append(n.children, child)
+	}
+
+	if len(path) > 1 {
+		insertKeyNode(child, path[1:], v)
+		return
+	}
+	for _, ga := range v.Group() {
+		insertKeyNode(child, strings.Split(ga.Key, "."), ga.Value.Resolve())
+	}
+}
+
+func keyNodeAttrs(n *keyNode) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(n.children))
+	for _, c := range n.children {
+		if c.leaf {
+			attrs = append(attrs, slog.Attr{Key: c.key, Value: c.value})
+			continue
+		}
+		attrs = append(attrs, slog.Attr{Key: c.key, Value: slog.GroupValue(keyNodeAttrs(c)...)})
+	}
+	return attrs
+}
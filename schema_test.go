@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestNormalizeKeyMapping(t *testing.T) {
+	tests := []struct {
+		schema Schema
+		key    string
+		want   string
+	}{
+		{SchemaECS, "user_id", "user.id"},
+		{SchemaECS, "userId", "user.id"},
+		{SchemaECS, "User-ID", "user.id"},
+		{SchemaECS, "request_id", "http.request.id"},
+		{SchemaECS, "status", "http.response.status_code"},
+		{SchemaECS, "client_ip", "client.ip"},
+		{SchemaECS, "trace_id", "trace.id"},
+		{SchemaECS, "stack", "error.stack_trace"},
+		{SchemaECS, "plan", "plan"},
+		{SchemaOTel, "user_id", "enduser.id"},
+		{SchemaOTel, "client_ip", "client.address"},
+		{SchemaOTel, "traceId", "trace_id"},
+		{SchemaOTel, "stack", "exception.stacktrace"},
+		{SchemaOTel, "username", "username"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		slog.New(NewNormalizeHandler(slog.NewJSONHandler(&buf, nil), &NormalizeOptions{Schema: tt.schema})).Info("x", tt.key, "v")
+		m := decodeJSONLine(t, &buf)
+		if m[tt.want] != "v" {
+			t.Errorf("schema %d: %q not renamed to %q: %v", tt.schema, tt.key, tt.want, m)
+		}
+	}
+}
+
+func TestNormalizeValues(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewNormalizeHandler(slog.NewJSONHandler(&buf, nil), &NormalizeOptions{
+		Mapping: map[string]string{"tenant": "organization.id", "status": "outcome"},
+		Nest:    true,
+	})
+	l := slog.New(h).With("user_id", 7)
+
+	l.Info("done", "err", errors.New("boom"), "duration", 2*time.Millisecond, "user_name", "bob", "tenant", "acme", "status", "ok")
+	m := decodeJSONLine(t, &buf)
+
+	errGroup := m["error"].(map[string]any)
+	if errGroup["message"] != "boom" || errGroup["type"] != "*errors.errorString" {
+		t.Fatalf("error = %v", errGroup)
+	}
+	if ev := m["event"].(map[string]any); ev["duration"] != 2e6 {
+		t.Fatalf("event.duration = %v, want nanoseconds", ev["duration"])
+	}
+	if user := m["user"].(map[string]any); user["name"] != "bob" {
+		t.Fatalf("user = %v", user)
+	}
+	if m["organization"].(map[string]any)["id"] != "acme" || m["outcome"] != "ok" {
+		t.Fatalf("custom mapping not applied: %v", m)
+	}
+
+	l.WithGroup("req").Info("grouped", "user_id", 8)
+	m = decodeJSONLine(t, &buf)
+	if req := m["req"].(map[string]any); req["user_id"] != 8.0 {
+		t.Fatalf("key inside a group renamed: %v", m)
+	}
+}
+
+func TestSchemaHandler(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(NewSchemaHandler(&buf, SchemaECS, &HandlerOptions{AddSource: true})).Warn("disk low", "host", "web-1")
+	m := decodeJSONLine(t, &buf)
+	if m["message"] != "disk low" || m["log.level"] != "warn" || m["@timestamp"] == nil || m["ecs.version"] != ecsVersion {
+		t.Fatalf("ECS record = %v", m)
+	}
+	if m["host"].(map[string]any)["name"] != "web-1" {
+		t.Fatalf("host = %v", m["host"])
+	}
+	origin, _ := m["log.origin"].(map[string]any)
+	if origin["function"] == nil || origin["file"].(map[string]any)["line"] == nil {
+		t.Fatalf("log.origin = %v", m["log.origin"])
+	}
+
+	slog.New(NewSchemaHandler(&buf, SchemaOTel, &HandlerOptions{AddSource: true})).Error("failed", "error", errors.New("boom"))
+	m = decodeJSONLine(t, &buf)
+	if m["body"] != "failed" || m["severity_text"] != "ERROR" || m["severity_number"] != 17.0 || m["timestamp"] == nil {
+		t.Fatalf("OTel record = %v", m)
+	}
+	if m["code.lineno"] == nil || m["exception"].(map[string]any)["message"] != "boom" {
+		t.Fatalf("OTel record = %v", m)
+	}
+}
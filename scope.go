@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// rootScope remembers the WithAttrs/WithGroup calls made on a wrapping handler,
+// so attributes that must stay at the root level of the output (trace IDs, the Cloud Logging
+// trace) can be added even when the logger has open groups.
+type rootScope struct {
+	// base is the root handler with the attrs added before the first group.
+	base Handler
+	// goas are the calls from the first WithGroup on, they are replayed when a group is open.
+	goas []groupOrAttrs
+	// next is base with goas applied, used when there is nothing to inject.
+	next Handler
+}
+
+func newRootScope(root Handler) rootScope {
+	return rootScope{base: root, next: root}
+}
+
+func (s rootScope) withAttrs(attrs []slog.Attr) rootScope {
+	if len(s.goas) == 0 {
+		h := s.base.WithAttrs(attrs)
+		return rootScope{base: h, next: h}
+	}
+	return rootScope{
+		base: s.base,
+		goas: appendGroupOrAttrs(s.goas, groupOrAttrs{attrs: attrs}),
+		next: s.next.WithAttrs(attrs),
+	}
+}
+
+// withRootAttrs adds attrs at the root level even when groups are open, the groups are replayed once.
+func (s rootScope) withRootAttrs(attrs []slog.Attr) rootScope {
+	s2 := rootScope{base: s.base.WithAttrs(attrs), goas: s.goas}
+	s2.next = s2.base
+	for _, goa := range s.goas {
+		if goa.group != "" {
+			s2.next = s2.next.WithGroup(goa.group)
+		} else {
+			s2.next = s2.next.WithAttrs(goa.attrs)
+		}
+	}
+	return s2
+}
+
+func (s rootScope) withGroup(name string) rootScope {
+	return rootScope{
+		base: s.base,
+		goas: appendGroupOrAttrs(s.goas, groupOrAttrs{group: name}),
+		next: s.next.WithGroup(name),
+	}
+}
+
+// handle passes r to the scoped handler with extra added before any group is opened. Without
+// open groups extra is added to the record, otherwise the groups are replayed on base with extra.
+func (s rootScope) handle(ctx context.Context, r slog.Record, extra []slog.Attr) error {
+	if len(extra) == 0 {
+		return s.next.Handle(ctx, r)
+	}
+	if len(s.goas) == 0 {
+		r = r.Clone()
+		r.AddAttrs(extra...)
+		return s.next.Handle(ctx, r)
+	}
+
+	return s.withRootAttrs(extra).next.Handle(ctx, r)
+}
+
+func appendGroupOrAttrs(goas []groupOrAttrs, goa groupOrAttrs) []groupOrAttrs {
+	out := make([]groupOrAttrs, len(goas)+1)
+	copy(out, goas)
+	out[len(goas)] = goa
+	return out
+}
@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"go/build"
+	"log/slog"
+	"net/url"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+const (
+	// SourceLinkFile links the source label to the file, terminals open it with the default application.
+	SourceLinkFile = "file:///{path}"
+	// SourceLinkVSCode opens the source line in Visual Studio Code.
+	SourceLinkVSCode = "vscode://file/{path}:{line}"
+)
+
+// SourceFormat shortens source locations, its ReplaceAttr method can be used in HandlerOptions directly.
+type SourceFormat struct {
+	// Relative shows paths relative to the module root, paths of other modules start with
+	// their import path and files under GOPATH are relative to GOPATH/src.
+	Relative bool
+	// Function keeps the function name, it is dropped otherwise.
+	Function bool
+}
+
+// ReplaceAttr shortens the source attr of the JSON and text handlers.
+func (f SourceFormat) ReplaceAttr(groups []string, a Attr) Attr {
+	if len(groups) > 0 || a.Key != slog.SourceKey {
+		return a
+	}
+	src, ok := a.Value.Any().(*slog.Source)
+	if !ok {
+		return a
+	}
+	short := f.format(*src)
+	a.Value = slog.AnyValue(&short)
+	return a
+}
+
+func (f SourceFormat) format(src slog.Source) slog.Source {
+	if f.Relative {
+		src.File = relativeSource(src.File, src.Function)
+	}
+	if f.Function {
+		src.Function = shortFunction(src.Function)
+	} else {
+		src.Function = ""
+	}
+	return src
+}
+
+var mainBuild = sync.OnceValues(func() (mainPkg, mainMod string) {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		return bi.Path, bi.Main.Path
+	}
+	return "", ""
+})
+
+// relativeSource returns file relative to the module root using the import path of the package of function.
+func relativeSource(file, function string) string {
+	pkg := funcPackage(function)
+	mainPkg, mainMod := mainBuild()
+	if pkg == "main" {
+		pkg = mainPkg
+	}
+	base := filepath.Base(file)
+
+	if mainMod != "" && (pkg == mainMod || strings.HasPrefix(pkg, mainMod+"/")) {
+		return path.Join(strings.TrimPrefix(strings.TrimPrefix(pkg, mainMod), "/"), base)
+	}
+	if src := filepath.Join(build.Default.GOPATH, "src") + string(filepath.Separator); strings.HasPrefix(file, src) {
+		return filepath.ToSlash(file[len(src):])
+	}
+	if pkg != "" && pkg != "main" {
+		return pkg + "/" + base
+	}
+	return file
+}
+
+// funcPackage returns the import path of the package of a function name as reported by runtime.Frame.
+func funcPackage(function string) string {
+	if i := strings.IndexByte(function, '['); i >= 0 {
+		function = function[:i]
+	}
+	slash := strings.LastIndexByte(function, '/') + 1
+	if dot := strings.IndexByte(function[slash:], '.'); dot >= 0 {
+		return function[:slash+dot]
+	}
+	return ""
+}
+
+// shortFunction drops the import path of the package from a function name.
+func shortFunction(function string) string {
+	name := function
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i]
+	}
+	return function[strings.LastIndexByte(name, '/')+1:]
+}
+
+// sourceLink expands the placeholders of a link template, {path} is the percent-encoded absolute
+// path with forward slashes and without the leading slash, so spaces and '#' do not break the link.
+func sourceLink(template, file, line string) string {
+	abs := filepath.ToSlash(file)
+	if !strings.HasPrefix(abs, "/") {
+		abs = "/" + abs
+	}
+	u := url.URL{Scheme: "file", Path: abs}
+	if template == SourceLinkFile {
+		return u.String()
+	}
+	return strings.NewReplacer("{path}", u.EscapedPath()[1:], "{line}", line).Replace(template)
+}
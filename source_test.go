@@ -0,0 +1,22 @@
+package logger
+
+import "testing"
+
+func TestSourceLink(t *testing.T) {
+	tests := []struct {
+		template, file string
+		want           string
+	}{
+		{SourceLinkFile, "/src/app/main.go", "file:///src/app/main.go"},
+		{SourceLinkFile, "/home/me/My Projects/#1/main.go", "file:///home/me/My%20Projects/%231/main.go"},
+		{SourceLinkFile, "/src/50%/ä.go", "file:///src/50%25/%C3%A4.go"},
+		{SourceLinkFile, "C:/src/app/main.go", "file:///C:/src/app/main.go"},
+		{SourceLinkVSCode, "/home/me/My Projects/main.go", "vscode://file/home/me/My%20Projects/main.go:42"},
+		{"idea://open?file={path}&line={line}", "/src/a#b.go", "idea://open?file=src/a%23b.go&line=42"},
+	}
+	for _, tt := range tests {
+		if got := sourceLink(tt.template, tt.file, "42"); got != tt.want {
+			t.Errorf("sourceLink(%q, %q) = %q, want %q", tt.template, tt.file, got, tt.want)
+		}
+	}
+}
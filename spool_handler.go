@@ -0,0 +1,577 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSpoolBatchSize     = 100
+	defaultSpoolFlushInterval = time.Second
+	defaultSpoolRetryInterval = 5 * time.Second
+	defaultSpoolSegmentSize   = 4 << 20
+	defaultSpoolMaxBytes      = 256 << 20
+
+	spoolSegmentExt = ".seg"
+)
+
+// BatchWriter is a network sink that accepts encoded records in batches.
+// A returned error means the whole batch has to be retried.
+type BatchWriter interface {
+	WriteBatch(ctx context.Context, records [][]byte) error
+}
+
+type SpoolOptions struct {
+	// Dir is the spool directory, required. It is created if it does not exist.
+	Dir       string
+	Level     slog.Leveler
+	AddSource bool
+
+	BatchSize     int
+	FlushInterval time.Duration
+	// RetryInterval is the pause between replay attempts while the sink is down.
+	RetryInterval time.Duration
+
+	// SegmentSize is the size after which a new segment file is started.
+	SegmentSize int64
+	// MaxBytes caps the spool size, the oldest segments are dropped first. It also caps the records
+	// held in memory while the sink is busy, the oldest of them are dropped first.
+	MaxBytes int64
+	// MaxAge drops segments whose newest record is older than MaxAge, zero keeps them forever.
+	MaxAge time.Duration
+
+	// OnError is called when the sink or the spool directory fails.
+	OnError func(error)
+}
+
+// SpoolStats is a snapshot of the spool state.
+type SpoolStats struct {
+	SpooledBytes   int64
+	Segments       int
+	OldestAge      time.Duration
+	PendingRecords int
+	Dropped        uint64
+}
+
+func (s SpoolStats) LogValue() Value {
+	return GroupValue(
+		Int64Attr("spooled_bytes", s.SpooledBytes),
+		IntAttr("segments", s.Segments),
+		DurationAttr("oldest_age", s.OldestAge),
+		IntAttr("pending_records", s.PendingRecords),
+		Uint64Attr("dropped", s.Dropped),
+	)
+}
+
+// SpoolHandler encodes records as JSON lines and sends them to a BatchWriter.
+// While the sink is unreachable batches are kept in segment files under SpoolOptions.Dir
+// and replayed in order once it recovers, also after a process restart.
+//
+// Delivery is at least once. The replay position within a segment is kept in memory only, so after
+// a restart a partially replayed segment is sent again from its start, and a batch that failed
+// after the sink stored part of it is retried as a whole. Records carry no ID of their own, sinks
+// that must not store duplicates have to dedupe them, for example by a hash of the line.
+type SpoolHandler struct {
+	core *spoolCore
+	enc  slog.Handler
+}
+
+type spoolSegment struct {
+	seq    uint64
+	path   string
+	size   int64
+	oldest time.Time
+	newest time.Time
+	offset int64
+}
+
+type spoolCore struct {
+	sink BatchWriter
+	opts SpoolOptions
+
+	encMu  sync.Mutex
+	encBuf bytes.Buffer
+
+	mu           sync.Mutex
+	pending      [][]byte
+	pendingBytes int64
+	segments     []*spoolSegment
+	active       *os.File
+	nextSeq      uint64
+	dropped      uint64
+	closed       bool
+
+	flush chan struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+var ErrSpoolClosed = errors.New("spool is closed")
+
+func NewSpoolHandler(sink BatchWriter, opts *SpoolOptions) (*SpoolHandler, error) {
+	if opts == nil || opts.Dir == "" {
+		return nil, errors.New("spool directory is required")
+	}
+
+	c := &spoolCore{
+		sink:  sink,
+		opts:  *opts,
+		flush: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+
+	if c.opts.Level == nil {
+		c.opts.Level = LevelInfo
+	}
+	if c.opts.BatchSize <= 0 {
+		c.opts.BatchSize = defaultSpoolBatchSize
+	}
+	if c.opts.FlushInterval <= 0 {
+		c.opts.FlushInterval = defaultSpoolFlushInterval
+	}
+	if c.opts.RetryInterval <= 0 {
+		c.opts.RetryInterval = defaultSpoolRetryInterval
+	}
+	if c.opts.SegmentSize <= 0 {
+		c.opts.SegmentSize = defaultSpoolSegmentSize
+	}
+	if c.opts.MaxBytes <= 0 {
+		c.opts.MaxBytes = defaultSpoolMaxBytes
+	}
+
+	if err := os.MkdirAll(c.opts.Dir, 0o750); err != nil {
+		return nil, err
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	h := &SpoolHandler{core: c}
+	h.enc = slog.NewJSONHandler(&c.encBuf, &slog.HandlerOptions{
+		Level:     c.opts.Level,
+		AddSource: c.opts.AddSource,
+	})
+
+	c.wg.Add(1)
+	go c.run()
+
+	return h, nil
+}
+
+/*--------------------------------slog methods-----------------------------------------------*/
+
+func (h *SpoolHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.core.opts.Level.Level()
+}
+
+func (h *SpoolHandler) Handle(ctx context.Context, r slog.Record) error {
+	c := h.core
+
+	c.encMu.Lock()
+	err := h.enc.Handle(ctx, r)
+	line := bytes.Clone(c.encBuf.Bytes())
+	c.encBuf.Reset()
+	c.encMu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	return c.append(line)
+}
+
+func (h *SpoolHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &SpoolHandler{core: h.core, enc: h.enc.WithAttrs(attrs)}
+}
+
+func (h *SpoolHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SpoolHandler{core: h.core, enc: h.enc.WithGroup(name)}
+}
+
+// Stats returns the current spool metrics.
+func (h *SpoolHandler) Stats() SpoolStats {
+	c := h.core
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := SpoolStats{
+		Segments:       len(c.segments),
+		PendingRecords: len(c.pending),
+		Dropped:        c.dropped,
+	}
+	for _, seg := range c.segments {
+		s.SpooledBytes += seg.size - seg.offset
+	}
+	if len(c.segments) > 0 && !c.segments[0].oldest.IsZero() {
+		s.OldestAge = time.Since(c.segments[0].oldest)
+	}
+	return s
+}
+
+// Close flushes pending records to the sink or the spool and stops the background worker.
+func (h *SpoolHandler) Close() error {
+	c := h.core
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	close(c.done)
+	c.wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active != nil {
+		return c.active.Close()
+	}
+	return nil
+}
+
+/*--------------------------------CORE-------------------------------------------------------*/
+
+func (c *spoolCore) append(line []byte) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrSpoolClosed
+	}
+	c.pending = append(c.pending, line)
+	c.pendingBytes += int64(len(line))
+	// a stalled sink must not take all the memory
+	for c.pendingBytes > c.opts.MaxBytes && len(c.pending) > 1 {
+		c.pendingBytes -= int64(len(c.pending[0]))
+		c.pending[0] = nil
+		c.pending = c.pending[1:]
+		c.dropped++
+	}
+	full := len(c.pending) >= c.opts.BatchSize
+	c.mu.Unlock()
+
+	if full {
+		select {
+		case c.flush <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (c *spoolCore) run() {
+	defer c.wg.Done()
+
+	flushTick := time.NewTicker(c.opts.FlushInterval)
+	defer flushTick.Stop()
+	retryTick := time.NewTicker(c.opts.RetryInterval)
+	defer retryTick.Stop()
+
+	c.replay()
+	for {
+		select {
+		case <-c.flush:
+			c.flushPending()
+		case <-flushTick.C:
+			c.flushPending()
+		case <-retryTick.C:
+			c.replay()
+			c.expire()
+		case <-c.done:
+			c.replay()
+			c.flushPending()
+			return
+		}
+	}
+}
+
+// flushPending sends the in-memory batch, or spools it when older records are still waiting
+// or the sink fails, so the delivery order is preserved.
+func (c *spoolCore) flushPending() {
+	c.mu.Lock()
+	batch := c.pending
+	c.pending, c.pendingBytes = nil, 0
+	spooling := len(c.segments) > 0
+	c.mu.Unlock()
+
+	for len(batch) > 0 {
+		n := min(len(batch), c.opts.BatchSize)
+		chunk := batch[:n]
+
+		if !spooling {
+			err := c.sink.WriteBatch(context.Background(), chunk)
+			if err == nil {
+				batch = batch[n:]
+				continue
+			}
+			c.reportError(fmt.Errorf("spool: sink: %w", err))
+			spooling = true
+		}
+
+		c.mu.Lock()
+		err := c.writeSpool(batch)
+		c.mu.Unlock()
+		if err != nil {
+			c.reportError(fmt.Errorf("spool: %w", err))
+		}
+		return
+	}
+}
+
+// replay sends spooled segments to the sink, oldest first, and stops at the first failure.
+func (c *spoolCore) replay() {
+	for {
+		c.mu.Lock()
+		if len(c.segments) == 0 {
+			c.mu.Unlock()
+			return
+		}
+		seg := c.segments[0]
+		if c.active != nil && c.active.Name() == seg.path {
+			c.active.Close()
+			c.active = nil
+		}
+		c.mu.Unlock()
+
+		records, ends, stamps, err := readSpoolSegment(seg.path, seg.offset)
+		if err != nil {
+			c.reportError(fmt.Errorf("spool: %w", err))
+			c.mu.Lock()
+			c.removeSegment(seg)
+			c.mu.Unlock()
+			continue
+		}
+
+		for i := 0; i < len(records); i += c.opts.BatchSize {
+			j := min(i+c.opts.BatchSize, len(records))
+			if err := c.sink.WriteBatch(context.Background(), records[i:j]); err != nil {
+				c.reportError(fmt.Errorf("spool: replay: %w", err))
+				return
+			}
+			c.mu.Lock()
+			seg.offset = ends[j-1]
+			if j < len(records) {
+				seg.oldest = stamps[j]
+			}
+			c.mu.Unlock()
+		}
+
+		c.mu.Lock()
+		c.removeSegment(seg)
+		c.mu.Unlock()
+	}
+}
+
+// writeSpool appends records to the active segment, it must be called with c.mu held.
+func (c *spoolCore) writeSpool(records [][]byte) error {
+	now := time.Now()
+	stamp := strconv.FormatInt(now.UnixNano(), 10) + " "
+
+	for _, rec := range records {
+		if err := c.ensureActive(now); err != nil {
+			return err
+		}
+		seg := c.segments[len(c.segments)-1]
+
+		n, err := c.active.WriteString(stamp)
+		if err == nil {
+			var m int
+			m, err = c.active.Write(rec)
+			n += m
+		}
+		seg.size += int64(n)
+		seg.newest = now
+		if err != nil {
+			return err
+		}
+	}
+
+	c.enforceSize()
+	return nil
+}
+
+func (c *spoolCore) ensureActive(now time.Time) error {
+	if c.active != nil {
+		seg := c.segments[len(c.segments)-1]
+		if seg.size < c.opts.SegmentSize {
+			return nil
+		}
+		c.active.Close()
+		c.active = nil
+	}
+
+	seq := c.nextSeq
+	c.nextSeq++
+	path := filepath.Join(c.opts.Dir, fmt.Sprintf("%016x%s", seq, spoolSegmentExt))
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	c.active = f
+	c.segments = append(c.segments, &spoolSegment{seq: seq, path: path, oldest: now, newest: now})
+	return nil
+}
+
+func (c *spoolCore) enforceSize() {
+	var total int64
+	for _, seg := range c.segments {
+		total += seg.size - seg.offset
+	}
+	for total > c.opts.MaxBytes && len(c.segments) > 1 {
+		seg := c.segments[0]
+		total -= seg.size - seg.offset
+		c.dropSegment(seg)
+	}
+}
+
+func (c *spoolCore) expire() {
+	if c.opts.MaxAge <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.segments) > 0 && time.Since(c.segments[0].newest) > c.opts.MaxAge {
+		seg := c.segments[0]
+		if c.active != nil && c.active.Name() == seg.path {
+			c.active.Close()
+			c.active = nil
+		}
+		c.dropSegment(seg)
+	}
+}
+
+func (c *spoolCore) dropSegment(seg *spoolSegment) {
+	if records, _, _, err := readSpoolSegment(seg.path, seg.offset); err == nil {
+		c.dropped += uint64(len(records))
+	}
+	c.removeSegment(seg)
+}
+
+func (c *spoolCore) removeSegment(seg *spoolSegment) {
+	if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.reportError(fmt.Errorf("spool: %w", err))
+	}
+	for i, s := range c.segments {
+		if s == seg {
+			c.segments = append(c.segments[:i], c.segments[i+1:]...)
+			break
+		}
+	}
+}
+
+// load picks up segments left by a previous process.
+func (c *spoolCore) load() error {
+	entries, err := os.ReadDir(c.opts.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, spoolSegmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolSegmentExt), 16, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+
+		seg := &spoolSegment{
+			seq:    seq,
+			path:   filepath.Join(c.opts.Dir, name),
+			size:   info.Size(),
+			newest: info.ModTime(),
+			oldest: readSpoolOldest(filepath.Join(c.opts.Dir, name)),
+		}
+		c.segments = append(c.segments, seg)
+		if seq >= c.nextSeq {
+			c.nextSeq = seq + 1
+		}
+	}
+
+	sort.Slice(c.segments, func(i, j int) bool { return c.segments[i].seq < c.segments[j].seq })
+	return nil
+}
+
+func (c *spoolCore) reportError(err error) {
+	if c.opts.OnError != nil {
+		c.opts.OnError(err)
+	}
+}
+
+/*--------------------------------SEGMENTS---------------------------------------------------*/
+
+// readSpoolSegment returns the records stored after offset, the file offset following each record
+// and the time each record was spooled.
+func readSpoolSegment(path string, offset int64) ([][]byte, []int64, []time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	var records [][]byte
+	var ends []int64
+	var stamps []time.Time
+	pos := offset
+	for _, line := range bytes.SplitAfter(data[offset:], []byte("\n")) {
+		pos += int64(len(line))
+		if len(line) == 0 || line[len(line)-1] != '\n' {
+			// torn write from a crash
+			break
+		}
+		var stamp time.Time
+		if i := bytes.IndexByte(line, ' '); i >= 0 {
+			if ns, err := strconv.ParseInt(string(line[:i]), 10, 64); err == nil {
+				stamp = time.Unix(0, ns)
+			}
+			line = line[i+1:]
+		}
+		records = append(records, line)
+		ends = append(ends, pos)
+		stamps = append(stamps, stamp)
+	}
+	return records, ends, stamps, nil
+}
+
+func readSpoolOldest(path string) time.Time {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}
+	}
+	defer f.Close()
+
+	stamp, err := bufio.NewReader(f).ReadString(' ')
+	if err != nil {
+		return time.Time{}
+	}
+	ns, err := strconv.ParseInt(strings.TrimSpace(stamp), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,211 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSink accepts the first ok batches and fails the rest.
+type testSink struct {
+	mu      sync.Mutex
+	ok      int
+	records []int
+}
+
+func (s *testSink) WriteBatch(_ context.Context, records [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ok <= 0 {
+		return errors.New("sink down")
+	}
+	s.ok--
+	for _, rec := range records {
+		var m struct{ I int }
+		if err := json.Unmarshal(rec, &m); err != nil {
+			return err
+		}
+		s.records = append(s.records, m.I)
+	}
+	return nil
+}
+
+func (s *testSink) received() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.records...)
+}
+
+// newTestSpool returns a spool whose worker only replays on start and acts on Close, the tests
+// flush, replay and expire by calling the core directly. A BatchSize above the logged records
+// keeps the worker from flushing early.
+func newTestSpool(t *testing.T, dir string, sink BatchWriter, opts SpoolOptions) *SpoolHandler {
+	t.Helper()
+	opts.Dir = dir
+	if opts.BatchSize == 0 {
+		opts.BatchSize = 1000
+	}
+	opts.FlushInterval = time.Hour
+	opts.RetryInterval = time.Hour
+	h, err := NewSpoolHandler(sink, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+// spool logs n records, they are kept in memory until flushPending.
+func spool(h *SpoolHandler, from, n int) {
+	l := slog.New(h)
+	for i := from; i < from+n; i++ {
+		l.Info("event", "i", i)
+	}
+}
+
+func segmentFiles(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestSpoolRotatesSegments(t *testing.T) {
+	dir := t.TempDir()
+	h := newTestSpool(t, dir, &testSink{}, SpoolOptions{SegmentSize: 256})
+
+	spool(h, 0, 20)
+	if s := h.Stats(); s.PendingRecords != 20 || s.Segments != 0 {
+		t.Fatalf("stats before flush = %+v", s)
+	}
+
+	h.core.flushPending()
+	s := h.Stats()
+	if s.PendingRecords != 0 || s.Segments < 3 || s.Segments != segmentFiles(t, dir) || s.Dropped != 0 {
+		t.Fatalf("stats = %+v, %d files", s, segmentFiles(t, dir))
+	}
+
+	var size int64
+	n := 0
+	for _, seg := range h.core.segments {
+		info, err := os.Stat(seg.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += info.Size()
+		records, _, _, err := readSpoolSegment(seg.path, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n += len(records)
+	}
+	if s.SpooledBytes != size || n != 20 {
+		t.Fatalf("stats = %+v, %d bytes and %d records on disk", s, size, n)
+	}
+	if s.OldestAge < 0 || s.OldestAge > time.Minute {
+		t.Fatalf("oldest age = %v", s.OldestAge)
+	}
+}
+
+func TestSpoolMaxBytesDropsOldest(t *testing.T) {
+	dir := t.TempDir()
+	h := newTestSpool(t, dir, &testSink{}, SpoolOptions{SegmentSize: 256, MaxBytes: 600})
+
+	for i := 0; i < 40; i += 5 {
+		spool(h, i, 5)
+		h.core.flushPending()
+	}
+
+	s := h.Stats()
+	if s.Dropped == 0 || s.SpooledBytes > 600 {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	sink := &testSink{ok: 100}
+	h.core.sink = sink
+	h.core.replay()
+	kept := sink.received()
+	if uint64(len(kept))+s.Dropped != 40 || kept[len(kept)-1] != 39 {
+		t.Fatalf("replayed %v with %d dropped", kept, s.Dropped)
+	}
+	for i := 1; i < len(kept); i++ {
+		if kept[i] != kept[i-1]+1 {
+			t.Fatalf("replayed %v, want the newest records in order", kept)
+		}
+	}
+}
+
+func TestSpoolMaxAgeDropsSegments(t *testing.T) {
+	dir := t.TempDir()
+	h := newTestSpool(t, dir, &testSink{}, SpoolOptions{SegmentSize: 256, MaxAge: 10 * time.Millisecond})
+
+	spool(h, 0, 10)
+	h.core.flushPending()
+	h.core.expire()
+	if s := h.Stats(); s.Segments == 0 || s.Dropped != 0 {
+		t.Fatalf("fresh segments expired: %+v", s)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	h.core.expire()
+	if s := h.Stats(); s.Segments != 0 || s.Dropped != 10 || s.SpooledBytes != 0 {
+		t.Fatalf("stats = %+v", s)
+	}
+	if n := segmentFiles(t, dir); n != 0 {
+		t.Fatalf("%d segment files left", n)
+	}
+}
+
+func TestSpoolReplaysAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	h := newTestSpool(t, dir, &testSink{}, SpoolOptions{SegmentSize: 1024})
+	spool(h, 0, 20)
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := segmentFiles(t, dir); n < 2 {
+		t.Fatalf("%d segment files after Close with a failing sink", n)
+	}
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Now(), LevelInfo, "late", 0)); !errors.Is(err, ErrSpoolClosed) {
+		t.Fatalf("Handle after Close = %v", err)
+	}
+
+	// the sink takes one batch of the first segment and fails again
+	flaky := &testSink{ok: 1}
+	h = newTestSpool(t, dir, flaky, SpoolOptions{SegmentSize: 1024, BatchSize: 2})
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := flaky.received(); len(got) != 2 || got[0] != 0 || got[1] != 1 {
+		t.Fatalf("first restart delivered %v", got)
+	}
+
+	sink := &testSink{ok: 100}
+	h = newTestSpool(t, dir, sink, SpoolOptions{SegmentSize: 1024})
+	spool(h, 20, 3)
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// at least once: the partially replayed segment is sent again from its start
+	got := sink.received()
+	if len(got) != 23 {
+		t.Fatalf("second restart delivered %v", got)
+	}
+	for i, n := range got {
+		if n != i {
+			t.Fatalf("second restart delivered %v, want 0 to 22 in order", got)
+		}
+	}
+	if n := segmentFiles(t, dir); n != 0 {
+		t.Fatalf("%d segment files left after replay", n)
+	}
+}
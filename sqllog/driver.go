@@ -0,0 +1,431 @@
+// Package sqllog wraps database/sql drivers to log queries, execs and transactions
+// with the logger from the query context.
+package sqllog
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/FurmanovVitaliy/logger"
+)
+
+const maxArgLen = 256
+
+type Options struct {
+	// Level is used for successful statements and transactions, if not set, Debug is used.
+	Level slog.Leveler
+	// SlowThreshold logs statements that take longer at Warn, zero disables slow-query warnings.
+	SlowThreshold time.Duration
+	// NoArgs omits statement arguments.
+	NoArgs bool
+	// Redactor is applied to string arguments, logger.NewRedactor(nil) is used if nil.
+	Redactor *logger.Redactor
+}
+
+// Wrap returns a driver that logs every statement of d.
+func Wrap(d driver.Driver, opts *Options) driver.Driver {
+	o := &Options{}
+	if opts != nil {
+		*o = *opts
+	}
+	if o.Level == nil {
+		o.Level = logger.LevelDebug
+	}
+	if o.Redactor == nil {
+		o.Redactor = logger.NewRedactor(nil)
+	}
+	return &wrappedDriver{Driver: d, opts: o}
+}
+
+// Register registers a logging wrapper of d under name.
+func Register(name string, d driver.Driver, opts *Options) {
+	sql.Register(name, Wrap(d, opts))
+}
+
+// Open opens a database with the registered driver driverName wrapped for logging.
+func Open(driverName, dsn string, opts *Options) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	db.Close()
+
+	w := Wrap(d, opts).(*wrappedDriver)
+	c, err := w.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(c), nil
+}
+
+/*--------------------------------DRIVER-----------------------------------------------------*/
+
+type wrappedDriver struct {
+	driver.Driver
+	opts *Options
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c, opts: d.opts}, nil
+}
+
+func (d *wrappedDriver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.Driver.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &connector{Connector: c, driver: d}, nil
+	}
+	return &connector{Connector: dsnConnector{name: name, driver: d.Driver}, driver: d}, nil
+}
+
+type connector struct {
+	driver.Connector
+	driver *wrappedDriver
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: cn, opts: c.driver.opts}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+type dsnConnector struct {
+	name   string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.name)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+/*--------------------------------CONN-------------------------------------------------------*/
+
+type conn struct {
+	driver.Conn
+	opts *Options
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var s driver.Stmt
+	var err error
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = pc.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		c.opts.log(ctx, "sql prepare", query, nil, 0, -1, err)
+		return nil, err
+	}
+	ws := &stmt{Stmt: s, query: query, opts: c.opts}
+	if _, ok := s.(driver.ColumnConverter); ok {
+		return converterStmt{ws}, nil
+	}
+	return ws, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := ec.ExecContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+	c.opts.log(ctx, "sql exec", query, args, time.Since(start), rowsAffected(res, err), err)
+	return res, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := qc.QueryContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+	c.opts.log(ctx, "sql query", query, args, time.Since(start), -1, err)
+	return rows, err
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	var tx driver.Tx
+	var err error
+	if bc, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = bc.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.beginLegacy(opts)
+	}
+	c.opts.logTx(ctx, "sql begin", time.Since(start), err, slog.Bool("read_only", opts.ReadOnly))
+	if err != nil {
+		return nil, err
+	}
+	return &loggedTx{Tx: tx, ctx: ctx, opts: c.opts}, nil
+}
+
+// beginLegacy starts a transaction with Conn.Begin, which cannot apply options, so options other
+// than the defaults are rejected like database/sql does for unwrapped drivers.
+func (c *conn) beginLegacy(opts driver.TxOptions) (driver.Tx, error) {
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		return nil, errors.New("sql: driver does not support non-default isolation level")
+	}
+	if opts.ReadOnly {
+		return nil, errors.New("sql: driver does not support read-only transactions")
+	}
+	return c.Conn.Begin()
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+/*--------------------------------STMT-------------------------------------------------------*/
+
+type stmt struct {
+	driver.Stmt
+	query string
+	opts  *Options
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var res driver.Result
+	var err error
+	if ec, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = ec.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedToValues(args); err == nil {
+			res, err = s.Stmt.Exec(values)
+		}
+	}
+	s.opts.log(ctx, "sql exec", s.query, args, time.Since(start), rowsAffected(res, err), err)
+	return res, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if qc, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = qc.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedToValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	s.opts.log(ctx, "sql query", s.query, args, time.Since(start), -1, err)
+	return rows, err
+}
+
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// converterStmt forwards driver.ColumnConverter of statements that implement it, database/sql
+// converts arguments with it before they reach ExecContext and QueryContext.
+type converterStmt struct {
+	*stmt
+}
+
+func (s converterStmt) ColumnConverter(idx int) driver.ValueConverter {
+	return s.Stmt.(driver.ColumnConverter).ColumnConverter(idx)
+}
+
+/*--------------------------------TX---------------------------------------------------------*/
+
+type loggedTx struct {
+	driver.Tx
+	ctx  context.Context
+	opts *Options
+}
+
+func (t *loggedTx) Commit() error {
+	start := time.Now()
+	err := t.Tx.Commit()
+	t.opts.logTx(t.ctx, "sql commit", time.Since(start), err)
+	return err
+}
+
+func (t *loggedTx) Rollback() error {
+	start := time.Now()
+	err := t.Tx.Rollback()
+	t.opts.logTx(t.ctx, "sql rollback", time.Since(start), err)
+	return err
+}
+
+/*--------------------------------LOG--------------------------------------------------------*/
+
+func (o *Options) log(ctx context.Context, msg, query string, args []driver.NamedValue, d time.Duration, rows int64, err error) {
+	level := o.Level.Level()
+	slow := o.SlowThreshold > 0 && d >= o.SlowThreshold
+	switch {
+	case err != nil && !errors.Is(err, io.EOF):
+		level = logger.LevelError
+	case slow:
+		level = logger.LevelWarn
+	}
+
+	log := logger.ExtractLogger(ctx)
+	if !log.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("sql", normalizeQuery(query)),
+		slog.Duration("duration", d),
+	}
+	if !o.NoArgs && len(args) > 0 {
+		attrs = append(attrs, o.argsAttr(args))
+	}
+	if rows >= 0 {
+		attrs = append(attrs, slog.Int64("rows_affected", rows))
+	}
+	if slow {
+		attrs = append(attrs, slog.Bool("slow", true))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	log.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (o *Options) logTx(ctx context.Context, msg string, d time.Duration, err error, attrs ...slog.Attr) {
+	level := o.Level.Level()
+	if err != nil {
+		level = logger.LevelError
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	attrs = append(attrs, slog.Duration("duration", d))
+	logger.ExtractLogger(ctx).LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (o *Options) argsAttr(args []driver.NamedValue) slog.Attr {
+	attrs := make([]slog.Attr, 0, len(args))
+	for _, a := range args {
+		key := a.Name
+		if key == "" {
+			key = strconv.Itoa(a.Ordinal)
+		}
+		attrs = append(attrs, o.Redactor.ReplaceAttr([]string{"args"}, slog.Any(key, o.normalizeArg(a.Value))))
+	}
+	return slog.Attr{Key: "args", Value: slog.GroupValue(attrs...)}
+}
+
+func (o *Options) normalizeArg(v driver.Value) any {
+	switch x := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		if utf8.Valid(x) && len(x) <= maxArgLen {
+			return o.Redactor.Redact(string(x))
+		}
+		return fmt.Sprintf("<%d bytes>", len(x))
+	case string:
+		if len(x) > maxArgLen {
+			x = truncate(x, maxArgLen) + "…"
+		}
+		return o.Redactor.Redact(x)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	default:
+		return x
+	}
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	for n > 0 && n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func normalizeQuery(q string) string {
+	return strings.Join(strings.Fields(q), " ")
+}
+
+func rowsAffected(res driver.Result, err error) int64 {
+	if err != nil || res == nil {
+		return -1
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func namedToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		if a.Name != "" {
+			return nil, errors.New("sqllog: driver does not support named parameters")
+		}
+		values[i] = a.Value
+	}
+	return values, nil
+}
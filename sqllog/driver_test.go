@@ -0,0 +1,355 @@
+package sqllog
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FurmanovVitaliy/logger"
+)
+
+const fakeDriverName = "sqllog-fake"
+
+var registerFake sync.Once
+
+// fakeDriver implements only the original driver interfaces: Open, Prepare and Begin,
+// so every *Context call of the wrapper takes its fallback path.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	if strings.Contains(query, "syntax error") {
+		return nil, errors.New("fake: syntax error")
+	}
+	if strings.Contains(query, "upper") {
+		return upperStmt{&fakeStmt{query: query}}, nil
+	}
+	return &fakeStmt{query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+type fakeStmt struct {
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if strings.Contains(s.query, "pg_sleep") {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return driver.RowsAffected(len(args)), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{}, nil
+}
+
+// upperStmt converts string arguments to upper case with driver.ColumnConverter.
+type upperStmt struct {
+	*fakeStmt
+}
+
+func (upperStmt) ColumnConverter(int) driver.ValueConverter {
+	return upperConverter{}
+}
+
+type upperConverter struct{}
+
+func (upperConverter) ConvertValue(v any) (driver.Value, error) {
+	if s, ok := v.(string); ok {
+		return strings.ToUpper(s), nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+type fakeRows struct{}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"id"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next([]driver.Value) error {
+	return io.EOF
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+// open returns a database of the fake driver wrapped with opts and a context whose logger writes to the buffer.
+func open(t *testing.T, opts *Options) (*sql.DB, context.Context, *bytes.Buffer) {
+	t.Helper()
+	registerFake.Do(func() { sql.Register(fakeDriverName, fakeDriver{}) })
+
+	db, err := Open(fakeDriverName, "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var buf bytes.Buffer
+	log := logger.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: logger.LevelDebug}))
+	return db, logger.ContextWithLogger(context.Background(), log), &buf
+}
+
+type record struct {
+	Level string         `json:"level"`
+	Msg   string         `json:"msg"`
+	SQL   string         `json:"sql"`
+	Args  map[string]any `json:"args"`
+	Rows  *int64         `json:"rows_affected"`
+	Slow  bool           `json:"slow"`
+	Error string         `json:"error"`
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []record {
+	t.Helper()
+	var out []record
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func TestExecFallsBackToPreparedStatement(t *testing.T) {
+	db, ctx, buf := open(t, nil)
+
+	res, err := db.ExecContext(ctx, "UPDATE users\n\tSET name = ?  WHERE id = ?", "bob", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Fatalf("rows affected = %d, want the 2 positional args to reach Exec", n)
+	}
+
+	recs := records(t, buf)
+	if len(recs) != 1 {
+		t.Fatalf("records = %+v, want one exec after the ErrSkip fallback", recs)
+	}
+	r := recs[0]
+	if r.Msg != "sql exec" || r.Level != "DEBUG" || r.SQL != "UPDATE users SET name = ? WHERE id = ?" {
+		t.Fatalf("record = %+v", r)
+	}
+	if r.Args["1"] != "bob" || r.Args["2"] != 7.0 || r.Rows == nil || *r.Rows != 2 {
+		t.Fatalf("record = %+v", r)
+	}
+}
+
+func TestQueryFallsBackToPreparedStatement(t *testing.T) {
+	db, ctx, buf := open(t, nil)
+
+	rows, err := db.QueryContext(ctx, "SELECT id FROM users WHERE id = ?", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	recs := records(t, buf)
+	if len(recs) != 1 || recs[0].Msg != "sql query" || recs[0].Rows != nil {
+		t.Fatalf("records = %+v", recs)
+	}
+}
+
+func TestNamedArgsRejectedByLegacyDriver(t *testing.T) {
+	db, ctx, buf := open(t, nil)
+
+	_, err := db.ExecContext(ctx, "DELETE FROM users WHERE id = :id", sql.Named("id", 1))
+	if err == nil || !strings.Contains(err.Error(), "named parameters") {
+		t.Fatalf("err = %v", err)
+	}
+
+	recs := records(t, buf)
+	if len(recs) != 1 || recs[0].Level != "ERROR" || !strings.Contains(recs[0].Error, "named parameters") {
+		t.Fatalf("records = %+v", recs)
+	}
+}
+
+func TestNamedToValues(t *testing.T) {
+	values, err := namedToValues([]driver.NamedValue{{Ordinal: 1, Value: "a"}, {Ordinal: 2, Value: int64(2)}})
+	if err != nil || len(values) != 2 || values[0] != "a" || values[1] != int64(2) {
+		t.Fatalf("values = %v, err = %v", values, err)
+	}
+	if _, err := namedToValues([]driver.NamedValue{{Name: "id", Ordinal: 1, Value: 1}}); err == nil {
+		t.Fatal("named value accepted")
+	}
+}
+
+func TestSlowQueryWarns(t *testing.T) {
+	db, ctx, buf := open(t, &Options{SlowThreshold: 10 * time.Millisecond})
+
+	if _, err := db.ExecContext(ctx, "SELECT pg_sleep(?)", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "SELECT ?", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	recs := records(t, buf)
+	if len(recs) != 2 {
+		t.Fatalf("records = %+v", recs)
+	}
+	if recs[0].Level != "WARN" || !recs[0].Slow {
+		t.Fatalf("slow record = %+v", recs[0])
+	}
+	if recs[1].Level != "DEBUG" || recs[1].Slow {
+		t.Fatalf("fast record = %+v", recs[1])
+	}
+}
+
+func TestArgsRedacted(t *testing.T) {
+	db, ctx, buf := open(t, nil)
+
+	if _, err := db.ExecContext(ctx, "INSERT INTO users (email, note) VALUES (?, ?)", "alice@example.com", []byte("plain")); err != nil {
+		t.Fatal(err)
+	}
+
+	recs := records(t, buf)
+	if len(recs) != 1 {
+		t.Fatalf("records = %+v", recs)
+	}
+	if got := recs[0].Args["1"]; got == "alice@example.com" || !strings.Contains(got.(string), "REDACTED") {
+		t.Fatalf("email arg = %v", got)
+	}
+	if got := recs[0].Args["2"]; got != "plain" {
+		t.Fatalf("bytes arg = %v", got)
+	}
+
+	db, ctx, buf = open(t, &Options{NoArgs: true})
+	if _, err := db.ExecContext(ctx, "SELECT ?", "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if recs := records(t, buf); len(recs) != 1 || recs[0].Args != nil {
+		t.Fatalf("records = %+v", recs)
+	}
+}
+
+func TestTransactionsLogged(t *testing.T) {
+	db, ctx, buf := open(t, nil)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	tx, err = db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []string
+	for _, r := range records(t, buf) {
+		msgs = append(msgs, r.Msg)
+	}
+	want := []string{"sql begin", "sql commit", "sql begin", "sql rollback"}
+	if strings.Join(msgs, ",") != strings.Join(want, ",") {
+		t.Fatalf("messages = %v, want %v", msgs, want)
+	}
+}
+
+func TestLegacyBeginRejectsOptions(t *testing.T) {
+	for _, opts := range []*sql.TxOptions{
+		{ReadOnly: true},
+		{Isolation: sql.LevelSerializable},
+	} {
+		db, ctx, buf := open(t, nil)
+
+		if tx, err := db.BeginTx(ctx, opts); err == nil {
+			tx.Rollback()
+			t.Fatalf("BeginTx(%+v) succeeded on a driver without ConnBeginTx", *opts)
+		}
+		recs := records(t, buf)
+		if len(recs) != 1 || recs[0].Msg != "sql begin" || recs[0].Level != "ERROR" {
+			t.Fatalf("records = %+v", recs)
+		}
+	}
+}
+
+func TestColumnConverterForwarded(t *testing.T) {
+	db, ctx, buf := open(t, nil)
+
+	if _, err := db.ExecContext(ctx, "SELECT upper(?)", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	recs := records(t, buf)
+	if len(recs) != 1 || recs[0].Args["1"] != "BOB" {
+		t.Fatalf("records = %+v, want the argument converted by the statement", recs)
+	}
+}
+
+func TestLongArgTruncatedOnRuneBoundary(t *testing.T) {
+	db, ctx, buf := open(t, nil)
+
+	long := strings.Repeat("a", maxArgLen-1) + "éé"
+	if _, err := db.ExecContext(ctx, "SELECT ?", long); err != nil {
+		t.Fatal(err)
+	}
+	recs := records(t, buf)
+	if len(recs) != 1 {
+		t.Fatalf("records = %+v", recs)
+	}
+	if got, want := recs[0].Args["1"], strings.Repeat("a", maxArgLen-1)+"…"; got != want {
+		t.Fatalf("arg = %q, want %q", got, want)
+	}
+}
+
+func TestPrepareErrorLogged(t *testing.T) {
+	db, ctx, buf := open(t, nil)
+
+	if _, err := db.PrepareContext(ctx, "SELECT syntax error"); err == nil {
+		t.Fatal("prepare succeeded")
+	}
+	recs := records(t, buf)
+	if len(recs) != 1 || recs[0].Msg != "sql prepare" || recs[0].Level != "ERROR" {
+		t.Fatalf("records = %+v", recs)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package logger
+
+import "os"
+
+// enableVirtualTerminal reports whether the terminal of f understands ANSI escapes, they are always
+// supported outside Windows.
+func enableVirtualTerminal(*os.File) bool {
+	return true
+}
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/term"
+)
+
+// termWidth caches the width of a terminal, it is refreshed when the terminal is resized.
+type termWidth struct {
+	fd    int
+	width atomic.Int64
+}
+
+var termWidths struct {
+	mu sync.Mutex
+	m  map[int]*termWidth
+}
+
+// terminalWidth returns the shared width cache of the terminal fd.
+func terminalWidth(fd int) *termWidth {
+	termWidths.mu.Lock()
+	defer termWidths.mu.Unlock()
+
+	if tw, ok := termWidths.m[fd]; ok {
+		return tw
+	}
+	if termWidths.m == nil {
+		termWidths.m = make(map[int]*termWidth)
+		watchResize(refreshTermWidths)
+	}
+	tw := &termWidth{fd: fd}
+	tw.refresh()
+	termWidths.m[fd] = tw
+	return tw
+}
+
+// outputWidth resolves the width of out: an explicit width, then the COLUMNS variable, then the size
+// of the terminal out writes to. It returns nil for writers that are not terminals.
+func outputWidth(out io.Writer, width int) *termWidth {
+	if width > 0 {
+		return fixedWidth(width)
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return fixedWidth(n)
+	}
+	if f, ok := out.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		return terminalWidth(int(f.Fd()))
+	}
+	return nil
+}
+
+// fixedWidth returns a width that is not refreshed on resize.
+func fixedWidth(width int) *termWidth {
+	tw := &termWidth{fd: -1}
+	tw.width.Store(int64(width))
+	return tw
+}
+
+// get returns the cached width, ok is false if fd is not a terminal or t is nil.
+func (t *termWidth) get() (width int, ok bool) {
+	if t == nil {
+		return 0, false
+	}
+	w := t.width.Load()
+	return int(w), w > 0
+}
+
+func (t *termWidth) refresh() {
+	w, _, err := term.GetSize(t.fd)
+	if err != nil {
+		w = 0
+	}
+	t.width.Store(int64(w))
+}
+
+func refreshTermWidths() {
+	termWidths.mu.Lock()
+	defer termWidths.mu.Unlock()
+	for _, tw := range termWidths.m {
+		tw.refresh()
+	}
+}
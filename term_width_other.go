@@ -0,0 +1,6 @@
+//go:build !unix
+
+package logger
+
+// watchResize is a no-op where SIGWINCH is not available, widths are measured once.
+func watchResize(func()) {}
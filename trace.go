@@ -0,0 +1,218 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultTraceIDKey      = "trace_id"
+	defaultSpanIDKey       = "span_id"
+	defaultTraceSampledKey = "trace_sampled"
+
+	traceparentLen = 55
+	flagSampled    = 0x01
+)
+
+// TraceContext is a W3C trace context as carried by the traceparent header.
+type TraceContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+type ctxTrace struct{}
+
+// ParseTraceparent parses a traceparent header value: 00-<trace-id>-<parent-id>-<flags>.
+func ParseTraceparent(s string) (TraceContext, error) {
+	var tc TraceContext
+
+	if len(s) < traceparentLen || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return tc, ErrInvalidTraceparent
+	}
+	version, ok := decodeLowerHex(s[0:2])
+	if !ok || version[0] == 0xff {
+		return tc, ErrInvalidTraceparent
+	}
+	// version 00 has a fixed length, later versions may append fields
+	if version[0] == 0 && len(s) != traceparentLen || len(s) > traceparentLen && s[traceparentLen] != '-' {
+		return tc, ErrInvalidTraceparent
+	}
+
+	traceID, ok1 := decodeLowerHex(s[3:35])
+	spanID, ok2 := decodeLowerHex(s[36:52])
+	flags, ok3 := decodeLowerHex(s[53:55])
+	if !ok1 || !ok2 || !ok3 {
+		return tc, ErrInvalidTraceparent
+	}
+
+	copy(tc.TraceID[:], traceID)
+	copy(tc.SpanID[:], spanID)
+	tc.Flags = flags[0]
+	if !tc.IsValid() {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	return tc, nil
+}
+
+// NewTraceparent starts a new trace with random IDs.
+func NewTraceparent(sampled bool) TraceContext {
+	var tc TraceContext
+	rand.Read(tc.TraceID[:])
+	rand.Read(tc.SpanID[:])
+	if sampled {
+		tc.Flags = flagSampled
+	}
+	return tc
+}
+
+// Child returns the context of a new span in the same trace, for outgoing requests.
+func (tc TraceContext) Child() TraceContext {
+	rand.Read(tc.SpanID[:])
+	return tc
+}
+
+func (tc TraceContext) IsValid() bool {
+	return tc.TraceID != [16]byte{} && tc.SpanID != [8]byte{}
+}
+
+func (tc TraceContext) Sampled() bool {
+	return tc.Flags&flagSampled != 0
+}
+
+func (tc TraceContext) TraceIDString() string {
+	return hex.EncodeToString(tc.TraceID[:])
+}
+
+func (tc TraceContext) SpanIDString() string {
+	return hex.EncodeToString(tc.SpanID[:])
+}
+
+// String formats tc as a traceparent header value.
+func (tc TraceContext) String() string {
+	b := make([]byte, 0, traceparentLen)
+	b = append(b, "00-"...)
+	b = hex.AppendEncode(b, tc.TraceID[:])
+	b = append(b, '-')
+	b = hex.AppendEncode(b, tc.SpanID[:])
+	b = append(b, '-')
+	return string(hex.AppendEncode(b, []byte{tc.Flags}))
+}
+
+// ContextWithTraceparent adds a trace context to ctx.
+func ContextWithTraceparent(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, ctxTrace{}, tc)
+}
+
+// TraceFromContext returns the trace of ctx, an OpenTelemetry span context takes precedence
+// over one added with ContextWithTraceparent.
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	if ctx == nil {
+		return TraceContext{}, false
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return TraceContext{
+			TraceID: sc.TraceID(),
+			SpanID:  sc.SpanID(),
+			Flags:   byte(sc.TraceFlags()),
+		}, true
+	}
+	if tc, ok := ctx.Value(ctxTrace{}).(TraceContext); ok && tc.IsValid() {
+		return tc, true
+	}
+	return TraceContext{}, false
+}
+
+func contextTrace(ctx context.Context) (traceID, spanID string, sampled, ok bool) {
+	tc, ok := TraceFromContext(ctx)
+	if !ok {
+		return "", "", false, false
+	}
+	return tc.TraceIDString(), tc.SpanIDString(), tc.Sampled(), true
+}
+
+/*--------------------------------HANDLER----------------------------------------------------*/
+
+type TraceOptions struct {
+	// TraceIDKey, SpanIDKey and SampledKey name the added attributes,
+	// trace_id, span_id and trace_sampled by default. SampledKey "-" omits the flag.
+	TraceIDKey string
+	SpanIDKey  string
+	SampledKey string
+}
+
+type traceHandler struct {
+	scope rootScope
+	opts  TraceOptions
+}
+
+// NewTraceHandler returns a handler that adds the trace and span IDs of the record context
+// to every record at the root level, also when groups are open, so collectors find them.
+// See TraceFromContext.
+func NewTraceHandler(next Handler, opts *TraceOptions) Handler {
+	h := &traceHandler{scope: newRootScope(next)}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.TraceIDKey == "" {
+		h.opts.TraceIDKey = defaultTraceIDKey
+	}
+	if h.opts.SpanIDKey == "" {
+		h.opts.SpanIDKey = defaultSpanIDKey
+	}
+	if h.opts.SampledKey == "" {
+		h.opts.SampledKey = defaultTraceSampledKey
+	}
+	return h
+}
+
+func (h *traceHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.scope.next.Enabled(ctx, level)
+}
+
+func (h *traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	tc, ok := TraceFromContext(ctx)
+	if !ok {
+		return h.scope.next.Handle(ctx, r)
+	}
+
+	extra := []slog.Attr{
+		slog.String(h.opts.TraceIDKey, tc.TraceIDString()),
+		slog.String(h.opts.SpanIDKey, tc.SpanIDString()),
+	}
+	if h.opts.SampledKey != "-" {
+		extra = append(extra, slog.Bool(h.opts.SampledKey, tc.Sampled()))
+	}
+	return h.scope.handle(ctx, r, extra)
+}
+
+func (h *traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &traceHandler{scope: h.scope.withAttrs(attrs), opts: h.opts}
+}
+
+func (h *traceHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &traceHandler{scope: h.scope.withGroup(name), opts: h.opts}
+}
+
+func decodeLowerHex(s string) ([]byte, bool) {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return nil, false
+		}
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
@@ -0,0 +1,146 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{testTraceparent, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false},
+		{"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		tc, err := ParseTraceparent(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseTraceparent(%q) error = %v, want ok %v", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && tc.TraceIDString() != tt.in[3:35] {
+			t.Errorf("ParseTraceparent(%q) trace = %s", tt.in, tc.TraceIDString())
+		}
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	tc, err := ParseTraceparent(testTraceparent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.String() != testTraceparent || !tc.Sampled() {
+		t.Fatalf("String() = %s, sampled %v", tc, tc.Sampled())
+	}
+
+	child := tc.Child()
+	if child.TraceID != tc.TraceID || child.SpanID == tc.SpanID || child.Flags != tc.Flags {
+		t.Fatalf("Child() = %s of %s", child, tc)
+	}
+
+	tc = NewTraceparent(false)
+	parsed, err := ParseTraceparent(tc.String())
+	if err != nil || parsed != tc || parsed.Sampled() {
+		t.Fatalf("NewTraceparent round trip = %v, %v", parsed, err)
+	}
+}
+
+func TestTraceFromContextPrefersOpenTelemetry(t *testing.T) {
+	builtin, _ := ParseTraceparent(testTraceparent)
+	ctx := ContextWithTraceparent(context.Background(), builtin)
+
+	if tc, ok := TraceFromContext(ctx); !ok || tc != builtin {
+		t.Fatalf("TraceFromContext = %v, %v", tc, ok)
+	}
+
+	otel := NewTraceparent(true)
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    otel.TraceID,
+		SpanID:     otel.SpanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	if tc, ok := TraceFromContext(ctx); !ok || tc != otel {
+		t.Fatalf("TraceFromContext = %v, want the OpenTelemetry span %v", tc, otel)
+	}
+
+	if _, ok := TraceFromContext(context.Background()); ok {
+		t.Fatal("trace found in an empty context")
+	}
+}
+
+func decodeJSONLine(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	buf.Reset()
+	return m
+}
+
+func TestTraceHandlerAddsIDsAtRoot(t *testing.T) {
+	tc, _ := ParseTraceparent(testTraceparent)
+	ctx := ContextWithTraceparent(context.Background(), tc)
+
+	var buf bytes.Buffer
+	l := slog.New(NewTraceHandler(slog.NewJSONHandler(&buf, nil), nil))
+
+	l.InfoContext(ctx, "plain")
+	m := decodeJSONLine(t, &buf)
+	if m["trace_id"] != tc.TraceIDString() || m["span_id"] != tc.SpanIDString() || m["trace_sampled"] != true {
+		t.Fatalf("record = %v", m)
+	}
+
+	l.With("service", "api").WithGroup("req").With("id", 7).InfoContext(ctx, "grouped", "status", 200)
+	m = decodeJSONLine(t, &buf)
+	if m["trace_id"] != tc.TraceIDString() || m["span_id"] != tc.SpanIDString() || m["service"] != "api" {
+		t.Fatalf("trace IDs not at the root: %v", m)
+	}
+	req, _ := m["req"].(map[string]any)
+	if req["id"] != 7.0 || req["status"] != 200.0 || req["trace_id"] != nil {
+		t.Fatalf("group = %v", m["req"])
+	}
+
+	l.WithGroup("req").InfoContext(context.Background(), "untraced")
+	if m = decodeJSONLine(t, &buf); m["trace_id"] != nil {
+		t.Fatalf("trace IDs without a trace: %v", m)
+	}
+}
+
+func TestTraceHandlerKeys(t *testing.T) {
+	tc, _ := ParseTraceparent(testTraceparent)
+	ctx := ContextWithTraceparent(context.Background(), tc)
+
+	var buf bytes.Buffer
+	h := NewTraceHandler(slog.NewJSONHandler(&buf, nil), &TraceOptions{
+		TraceIDKey: "traceId",
+		SpanIDKey:  "spanId",
+		SampledKey: "-",
+	})
+	slog.New(h).InfoContext(ctx, "custom")
+
+	m := decodeJSONLine(t, &buf)
+	if m["traceId"] != tc.TraceIDString() || m["spanId"] != tc.SpanIDString() {
+		t.Fatalf("record = %v", m)
+	}
+	if _, ok := m["trace_sampled"]; ok || m["trace_id"] != nil {
+		t.Fatalf("default keys written: %v", m)
+	}
+}